@@ -4,27 +4,41 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/tekintian/go-bbdown/core"
+	"github.com/tekintian/go-bbdown/util"
 )
 
 var (
 	cfgFile          string
+	batchFile        string
 	useTVApi         bool
 	useAppApi        bool
 	useIntlApi       bool
 	useMP4Box        bool
 	encoding         string
 	quality          string
+	maxResolution    int
+	minResolution    int
+	maxFPS           int
+	maxBitrate       int
+	maxFileSize      string
+	audioPreference  string
+	container        string
+	audioFormat      string
 	onlyInfo         bool
 	showAll          bool
 	useAria2c        bool
 	interactive      bool
 	hideStreams      bool
 	multiThread      bool
+	concurrentTasks  int
+	maxConnections   int
 	simplyMux        bool
+	fastStart        bool
 	videoOnly        bool
 	audioOnly        bool
 	danmakuOnly      bool
@@ -32,6 +46,11 @@ var (
 	subOnly          bool
 	debug            bool
 	skipMux          bool
+	exportMPD        bool
+	exportHLS        bool
+	timeRange        string
+	steinGraph       string
+	keepAudio        bool
 	skipSub          bool
 	skipCover        bool
 	forceHTTP        bool
@@ -41,20 +60,29 @@ var (
 	audioAsc         bool
 	allowPCDN        bool
 	forceReplaceHost bool
+	insecure         bool
+	connectTimeout   time.Duration
+	responseTimeout  time.Duration
+	apiTimeout       time.Duration
+	recordDir        string
+	replayDir        string
 	filePattern      string
 	multiFilePattern string
 	selectPage       string
 	language         string
+	downloadArchive  string
 	userAgent        string
 	cookie           string
 	accessToken      string
 	aria2cArgs       string
 	workDir          string
+	tempDir          string
 	ffmpegPath       string
 	mp4boxPath       string
 	aria2cPath       string
 	uposHost         string
 	delayPerPage     string
+	apiRateLimit     float64
 	host             string
 	epHost           string
 	tvHost           string
@@ -63,7 +91,7 @@ var (
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "go-bbdown [url]",
+	Use:   "go-bbdown [url...]",
 	Short: "BBDown是一个免费且便捷高效的哔哩哔哩下载/解析软件",
 	Long: `BBDown是一个免费且便捷高效的哔哩哔哩下载/解析软件。
 
@@ -75,12 +103,57 @@ var rootCmd = &cobra.Command{
 - 完整链接: https://www.bilibili.com/video/BV1xx411c7mD
 - 番剧链接: https://www.bilibili.com/bangumi/play/ss123456
 - 合集链接: https://space.bilibili.com/89320896/lists/5348941?type=season
-- 媒体列表: https://www.bilibili.com/medialist/detail/ml123456`,
-	Args: cobra.MaximumNArgs(1),
+- 媒体列表: https://www.bilibili.com/medialist/detail/ml123456
+
+可以同时传入多个链接，或使用 --batch-file 从文件读取（每行一个，支持#注释），
+传入 - 时从标准输入读取。`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		var url string
-		if len(args) > 0 {
-			url = args[0]
+		urls, err := collectURLs(args, batchFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(urls) == 0 {
+			cmd.Help()
+			return
+		}
+
+		maxSize, err := util.ParseByteSize(maxFileSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: 无效的文件大小限制: %v\n", err)
+			os.Exit(1)
+		}
+
+		if audioPreference != "" && !containsString(core.AudioPreferences, strings.ToLower(audioPreference)) {
+			fmt.Fprintf(os.Stderr, "Error: 无效的音频偏好: %s, 可选: %s\n", audioPreference, strings.Join(core.AudioPreferences, ", "))
+			os.Exit(1)
+		}
+
+		container = strings.ToLower(container)
+		if container != "" && !containsString(core.Containers, container) {
+			fmt.Fprintf(os.Stderr, "Error: 无效的输出容器: %s, 可选: %s\n", container, strings.Join(core.Containers, ", "))
+			os.Exit(1)
+		}
+
+		audioFormat = strings.ToLower(audioFormat)
+		if audioFormat != "" && !containsString(core.AudioFormats, audioFormat) {
+			fmt.Fprintf(os.Stderr, "Error: 无效的音频格式: %s, 可选: %s\n", audioFormat, strings.Join(core.AudioFormats, ", "))
+			os.Exit(1)
+		}
+
+		if recordDir != "" && replayDir != "" {
+			fmt.Fprintf(os.Stderr, "Error: --record 和 --replay 不能同时使用\n")
+			os.Exit(1)
+		}
+
+		if _, err := core.ParseDelay(delayPerPage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if _, unknown := core.ParseQualityPriority(strings.Split(quality, ",")); len(unknown) > 0 {
+			fmt.Fprintf(os.Stderr, "警告: 无法识别的画质: %s, 将按名称模糊匹配\n", strings.Join(unknown, ", "))
 		}
 
 		config := &core.Config{
@@ -90,13 +163,24 @@ var rootCmd = &cobra.Command{
 			UseMP4Box:        useMP4Box,
 			EncodingPriority: strings.Split(encoding, ","),
 			QualityPriority:  strings.Split(quality, ","),
+			MaxResolution:    maxResolution,
+			MinResolution:    minResolution,
+			MaxFPS:           maxFPS,
+			MaxBitrate:       maxBitrate,
+			MaxFileSize:      maxSize,
+			AudioPreference:  audioPreference,
+			Container:        container,
+			AudioFormat:      audioFormat,
 			OnlyShowInfo:     onlyInfo,
 			ShowAll:          showAll,
 			UseAria2c:        useAria2c,
 			Interactive:      interactive,
 			HideStreams:      hideStreams,
 			MultiThread:      multiThread,
+			ConcurrentTasks:  concurrentTasks,
+			MaxConnections:   maxConnections,
 			SimplyMux:        simplyMux,
+			FastStart:        fastStart,
 			VideoOnly:        videoOnly,
 			AudioOnly:        audioOnly,
 			DanmakuOnly:      danmakuOnly,
@@ -104,6 +188,11 @@ var rootCmd = &cobra.Command{
 			SubOnly:          subOnly,
 			Debug:            debug,
 			SkipMux:          skipMux,
+			ExportMPD:        exportMPD,
+			ExportHLS:        exportHLS,
+			TimeRange:        timeRange,
+			SteinGraph:       steinGraph,
+			KeepAudio:        keepAudio,
 			SkipSubtitle:     skipSub,
 			SkipCover:        skipCover,
 			ForceHTTP:        forceHTTP,
@@ -113,33 +202,94 @@ var rootCmd = &cobra.Command{
 			AudioAscending:   audioAsc,
 			AllowPCDN:        allowPCDN,
 			ForceReplaceHost: forceReplaceHost,
+			Insecure:         insecure,
+			ConnectTimeout:   connectTimeout,
+			ResponseTimeout:  responseTimeout,
+			APITimeout:       apiTimeout,
+			RecordDir:        recordDir,
+			ReplayDir:        replayDir,
 			FilePattern:      filePattern,
 			MultiFilePattern: multiFilePattern,
 			SelectPage:       selectPage,
 			Language:         language,
+			DownloadArchive:  downloadArchive,
 			UserAgent:        userAgent,
 			Cookie:           cookie,
 			AccessToken:      accessToken,
 			Aria2cArgs:       aria2cArgs,
 			WorkDir:          workDir,
+			TempDir:          tempDir,
 			FFmpegPath:       ffmpegPath,
 			Mp4boxPath:       mp4boxPath,
 			Aria2cPath:       aria2cPath,
 			UposHost:         uposHost,
 			DelayPerPage:     delayPerPage,
+			APIRateLimit:     apiRateLimit,
 			Host:             host,
 			EpHost:           epHost,
 			TvHost:           tvHost,
 			Area:             area,
 		}
 
-		if err := core.Download(url, config); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if failed := runBatch(urls, config); failed > 0 {
 			os.Exit(1)
 		}
 	},
 }
 
+// collectURLs 汇总命令行参数和批量文件中的链接，参数为 - 时从标准输入读取
+func collectURLs(args []string, batchFile string) ([]string, error) {
+	var urls []string
+	for _, arg := range args {
+		if arg != "-" {
+			urls = append(urls, arg)
+			continue
+		}
+		list, err := util.ReadURLList(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("读取标准输入失败: %w", err)
+		}
+		urls = append(urls, list...)
+	}
+
+	if batchFile != "" {
+		file, err := os.Open(batchFile)
+		if err != nil {
+			return nil, fmt.Errorf("打开批量文件失败: %w", err)
+		}
+		defer file.Close()
+
+		list, err := util.ReadURLList(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取批量文件失败: %w", err)
+		}
+		urls = append(urls, list...)
+	}
+	return urls, nil
+}
+
+// runBatch 依次下载所有链接，多个链接时输出汇总，返回失败的数量
+func runBatch(urls []string, config *core.Config) int {
+	var failures []string
+	for i, url := range urls {
+		if len(urls) > 1 {
+			fmt.Printf("\n[%d/%d] %s\n", i+1, len(urls), url)
+		}
+		if err := core.Download(url, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failures = append(failures, fmt.Sprintf("%s: %v", url, err))
+		}
+	}
+
+	if len(urls) > 1 {
+		fmt.Printf("\n共%d个任务，成功%d个，失败%d个\n", len(urls), len(urls)-len(failures), len(failures))
+		for _, failure := range failures {
+			fmt.Printf("  失败: %s\n", failure)
+		}
+	}
+	return len(failures)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
 	return rootCmd.Execute()
@@ -157,21 +307,37 @@ func init() {
 	rootCmd.Flags().BoolVarP(&useIntlApi, "use-intl-api", "", false, "使用国际版(东南亚视频)解析模式")
 	rootCmd.Flags().BoolVar(&useMP4Box, "use-mp4box", false, "使用MP4Box来混流")
 	rootCmd.Flags().StringVarP(&encoding, "encoding-priority", "e", "", "视频编码的选择优先级,用逗号分割 例: \"hevc,av1,avc\"")
-	rootCmd.Flags().StringVarP(&quality, "dfn-priority", "q", "", "画质优先级,用逗号分隔 例: \"8K 超高清,1080P 高码率,HDR 真彩,杜比视界\"")
+	rootCmd.Flags().StringVarP(&quality, "dfn-priority", "q", "", "画质优先级,用逗号分隔,支持名称或画质代码 例: \"8K 超高清,1080P 高码率,HDR 真彩,杜比视界\" 或 \"127,112,80\"")
+	rootCmd.Flags().IntVar(&maxResolution, "max-resolution", 0, "最大分辨率(短边像素), 例: 1080")
+	rootCmd.Flags().IntVar(&minResolution, "min-resolution", 0, "最小分辨率(短边像素), 例: 720")
+	rootCmd.Flags().IntVar(&maxFPS, "max-fps", 0, "最大帧率, 例: 30")
+	rootCmd.Flags().IntVar(&maxBitrate, "max-bitrate", 0, "最大码率(kbps)")
+	rootCmd.Flags().StringVar(&maxFileSize, "max-filesize", "", "最大文件大小, 例: 500M, 2G")
+	rootCmd.Flags().StringVar(&audioPreference, "audio-preference", "", "音频偏好: hires, dolby, aac-highest, aac-lowest")
+	rootCmd.Flags().StringVar(&container, "container", "", "混流输出容器: mp4, mkv, mov (默认根据音频编码自动选择)")
+	rootCmd.Flags().StringVar(&audioFormat, "audio-format", "", "音频输出格式: m4a, flac, mp3, opus (默认保持原编码, 需要时通过FFmpeg转码)")
 	rootCmd.Flags().BoolVarP(&onlyInfo, "info", "i", false, "只展示信息, 不下载")
 	rootCmd.Flags().BoolVar(&showAll, "show-all", false, "展示所有信息")
 	rootCmd.Flags().BoolVar(&useAria2c, "use-aria2c", false, "使用aria2c进行下载")
 	rootCmd.Flags().BoolVarP(&interactive, "interactive", "", false, "交互式选择下载清晰度和编码")
 	rootCmd.Flags().BoolVar(&hideStreams, "hide-streams", false, "隐藏所有可用流信息")
 	rootCmd.Flags().BoolVar(&multiThread, "multi-thread", true, "是否使用多线程下载")
-	rootCmd.Flags().BoolVar(&simplyMux, "simply-mux", false, "简单混流(不合并音视频, 仅混合格式)")
+	rootCmd.Flags().IntVar(&concurrentTasks, "concurrent-tasks", 1, "同时下载的分P/视频数量")
+	rootCmd.Flags().IntVar(&maxConnections, "max-connections", 16, "全局下载连接数上限, 所有任务共享")
+	rootCmd.Flags().BoolVar(&simplyMux, "simply-mux", false, "简单混流(不写入语言、标题等元数据)")
+	rootCmd.Flags().BoolVar(&fastStart, "faststart", true, "内置混流器将moov放在文件开头(未安装FFmpeg时使用)")
 	rootCmd.Flags().BoolVar(&videoOnly, "video-only", false, "只下载视频")
 	rootCmd.Flags().BoolVar(&audioOnly, "audio-only", false, "只下载音频")
 	rootCmd.Flags().BoolVar(&danmakuOnly, "danmaku-only", false, "只下载弹幕")
 	rootCmd.Flags().BoolVar(&coverOnly, "cover-only", false, "只下载封面")
 	rootCmd.Flags().BoolVar(&subOnly, "sub-only", false, "只下载字幕")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "启用调试模式")
-	rootCmd.Flags().BoolVar(&skipMux, "skip-mux", false, "跳过混流")
+	rootCmd.Flags().BoolVar(&skipMux, "skip-mux", false, "跳过混流, 保留原始音视频流")
+	rootCmd.Flags().BoolVar(&exportMPD, "export-mpd", false, "只生成DASH MPD清单(引用B站CDN地址), 不下载")
+	rootCmd.Flags().BoolVar(&exportHLS, "export-hls", false, "只生成HLS播放列表(fMP4字节范围), 不下载")
+	rootCmd.Flags().StringVar(&steinGraph, "stein-graph", "", "互动视频额外导出剧情图: json, dot")
+	rootCmd.Flags().StringVar(&timeRange, "range", "", "只下载指定时间范围并精确剪切(需要FFmpeg), 例: 00:10:00-00:15:30")
+	rootCmd.Flags().BoolVar(&keepAudio, "keep-audio", false, "混流后单独保留音频文件")
 	rootCmd.Flags().BoolVar(&skipSub, "skip-subtitle", false, "跳过字幕下载")
 	rootCmd.Flags().BoolVar(&skipCover, "skip-cover", false, "跳过封面下载")
 	rootCmd.Flags().BoolVar(&forceHTTP, "force-http", true, "强制使用HTTP协议")
@@ -181,20 +347,30 @@ func init() {
 	rootCmd.Flags().BoolVar(&audioAsc, "audio-ascending", false, "音频编码按序升序")
 	rootCmd.Flags().BoolVar(&allowPCDN, "allow-pcdn", false, "允许PCDN")
 	rootCmd.Flags().BoolVar(&forceReplaceHost, "force-replace-host", true, "强制替换主机")
+	rootCmd.Flags().BoolVar(&insecure, "insecure", false, "跳过TLS证书校验(不安全, 仅用于自签名代理等场景)")
+	rootCmd.Flags().DurationVar(&connectTimeout, "connect-timeout", 10*time.Second, "建立连接(含TLS握手)的超时时间")
+	rootCmd.Flags().DurationVar(&responseTimeout, "response-timeout", 30*time.Second, "等待服务器响应头的超时时间")
+	rootCmd.Flags().DurationVar(&apiTimeout, "api-timeout", time.Minute, "单次API请求的总超时时间, 下载不限制总时长")
+	rootCmd.Flags().StringVar(&recordDir, "record", "", "将API请求和响应录制到指定目录(隐藏Cookie等敏感信息), 用于问题复现")
+	rootCmd.Flags().StringVar(&replayDir, "replay", "", "从指定目录回放录制的API响应, 不访问网络")
 
 	// 文件和路径相关
 	rootCmd.Flags().StringVar(&filePattern, "file-pattern", "", "单文件保存路径模板")
 	rootCmd.Flags().StringVar(&multiFilePattern, "multi-file-pattern", "", "多文件保存路径模板")
 	rootCmd.Flags().StringVar(&selectPage, "select-page", "", "选择指定分P")
-	rootCmd.Flags().StringVar(&language, "language", "", "选择音轨语言")
-	rootCmd.Flags().StringVar(&workDir, "work-dir", "", "工作目录")
+	rootCmd.Flags().StringVar(&language, "language", "", "选择配音语言, 多个用逗号分隔, all表示全部 例: \"zh-Hans,en-US\"")
+	rootCmd.Flags().StringVar(&batchFile, "batch-file", "", "批量下载文件, 每行一个链接, 支持#注释")
+	rootCmd.Flags().StringVar(&downloadArchive, "download-archive", "", "下载记录文件, 记录已完成的分P(bvid:cid:quality)并在之后跳过")
+	rootCmd.Flags().StringVar(&workDir, "work-dir", "", "工作目录(输出文件保存位置)")
+	rootCmd.Flags().StringVar(&tempDir, "temp-dir", "", "临时文件目录, 可位于其他磁盘 (默认在工作目录下)")
 
 	// 网络和认证相关
 	rootCmd.Flags().StringVar(&userAgent, "user-agent", "", "自定义User-Agent")
 	rootCmd.Flags().StringVar(&cookie, "cookie", "", "自定义Cookie")
 	rootCmd.Flags().StringVar(&accessToken, "access-token", "", "访问令牌")
 	rootCmd.Flags().StringVar(&uposHost, "upos-host", "", "UPOS主机")
-	rootCmd.Flags().StringVar(&delayPerPage, "delay-per-page", "0", "每页延迟时间")
+	rootCmd.Flags().StringVar(&delayPerPage, "delay-per-page", "0", "分P之间的下载间隔, 附加最多50%随机延迟 例: 3, 1.5s, 500ms")
+	rootCmd.Flags().Float64Var(&apiRateLimit, "api-rate-limit", 2, "每秒最多发起的API请求数, 0表示不限制")
 	rootCmd.Flags().StringVar(&host, "host", "api.bilibili.com", "API主机")
 	rootCmd.Flags().StringVar(&epHost, "ep-host", "api.bilibili.com", "EP API主机")
 	rootCmd.Flags().StringVar(&tvHost, "tv-host", "api.snm0516.aisee.tv", "TV API主机")
@@ -211,6 +387,16 @@ func init() {
 	viper.BindPFlag("use-intl-api", rootCmd.Flags().Lookup("use-intl-api"))
 }
 
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
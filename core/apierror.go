@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 常见API错误，可用 errors.Is 判断 *APIError 属于哪一类
+var (
+	ErrNotLoggedIn   = errors.New("账号未登录")
+	ErrAccessDenied  = errors.New("无访问权限")
+	ErrVideoNotFound = errors.New("视频不存在或已被删除")
+	ErrRegionLocked  = errors.New("所在地区不可观看")
+	ErrVIPOnly       = errors.New("需要大会员")
+)
+
+// apiErrorCodes API错误码对应的错误类别
+var apiErrorCodes = map[int]error{
+	-101:    ErrNotLoggedIn,
+	-401:    ErrNotLoggedIn, // 未认证
+	-403:    ErrAccessDenied,
+	-404:    ErrVideoNotFound,
+	62002:   ErrVideoNotFound, // 稿件不可见
+	62004:   ErrVideoNotFound, // 稿件审核中
+	-10403:  ErrRegionLocked,
+	6002105: ErrVIPOnly,
+}
+
+// APIError B站接口返回的业务错误(code不为0)
+type APIError struct {
+	Code      int    // 接口返回的错误码
+	Message   string // 接口返回的错误信息
+	Endpoint  string // 请求的接口，不含查询参数
+	RequestID string // 接口返回的请求ID，部分接口没有
+}
+
+// Error 返回错误信息
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API错误 %d: %s", e.Code, e.Message)
+	if e.Endpoint != "" {
+		msg += fmt.Sprintf(" (%s)", e.Endpoint)
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" [request_id=%s]", e.RequestID)
+	}
+	return msg
+}
+
+// Is 使 errors.Is(err, ErrVideoNotFound) 等判断成立
+// 地区限制和大会员限制共用 -10403，按错误信息区分
+func (e *APIError) Is(target error) bool {
+	if strings.Contains(e.Message, "大会员") {
+		return target == ErrVIPOnly
+	}
+	sentinel, ok := apiErrorCodes[e.Code]
+	return ok && sentinel == target
+}
+
+// checkAPIResponse 检查接口响应的code，不为0时返回 *APIError
+// 响应不是JSON时返回nil，由调用方自行处理
+func checkAPIResponse(endpoint, body string) error {
+	var resp struct {
+		Code      int    `json:"code"`
+		Message   string `json:"message"`
+		Msg       string `json:"msg"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || resp.Code == 0 {
+		return nil
+	}
+
+	message := resp.Message
+	if message == "" {
+		message = resp.Msg
+	}
+	return &APIError{
+		Code:      resp.Code,
+		Message:   message,
+		Endpoint:  apiEndpoint(endpoint),
+		RequestID: resp.RequestID,
+	}
+}
+
+// newAPIError 根据已解析的code和message创建 *APIError
+func newAPIError(endpoint string, code int, message string) *APIError {
+	return &APIError{Code: code, Message: message, Endpoint: apiEndpoint(endpoint)}
+}
+
+// apiEndpoint 去掉URL中的查询参数，避免错误信息中泄露签名和access_key
+func apiEndpoint(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host + u.Path
+}
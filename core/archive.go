@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// downloadArchive 下载记录，每行一条 bvid:cid:quality，用于跳过已下载的分P
+type downloadArchive struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]bool // 以 bvid:cid 为键
+}
+
+// loadDownloadArchive 读取下载记录文件，文件不存在时视为空记录
+func loadDownloadArchive(path string) (*downloadArchive, error) {
+	archive := &downloadArchive{path: path, entries: make(map[string]bool)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return archive, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取下载记录失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// 只按 bvid:cid 判断，画质仅作记录
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		archive.entries[parts[0]+":"+parts[1]] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取下载记录失败: %w", err)
+	}
+	return archive, nil
+}
+
+// Has 判断分P是否已下载
+func (a *downloadArchive) Has(id string, cid int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.entries[fmt.Sprintf("%s:%d", id, cid)]
+}
+
+// Add 记录已完成的分P并追加写入文件
+func (a *downloadArchive) Add(id string, cid int64, quality int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("写入下载记录失败: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s:%d:%d\n", id, cid, quality); err != nil {
+		return fmt.Errorf("写入下载记录失败: %w", err)
+	}
+	a.entries[fmt.Sprintf("%s:%d", id, cid)] = true
+	return nil
+}
+
+// openArchive 按配置加载下载记录，未启用时返回nil
+// 同一配置只加载一次，多个下载任务共享同一份记录
+func openArchive(config *Config) (*downloadArchive, error) {
+	if config.DownloadArchive == "" {
+		return nil, nil
+	}
+
+	config.archiveOnce.Do(func() {
+		config.archive, config.archiveErr = loadDownloadArchive(config.DownloadArchive)
+	})
+	return config.archive, config.archiveErr
+}
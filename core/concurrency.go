@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultMaxConnections 未配置时本次下载的连接数上限
+const defaultMaxConnections = 16
+
+// configureConnections 按配置创建本次下载的连接名额，同一配置下的所有下载任务共用
+func configureConnections(config *Config) {
+	limit := config.MaxConnections
+	if limit <= 0 {
+		limit = defaultMaxConnections
+	}
+	config.connections = make(chan struct{}, limit)
+}
+
+// acquireConnection 占用一个下载连接名额，返回释放函数
+// 未通过DownloadWithClient初始化名额时不限制
+func acquireConnection(config *Config) func() {
+	slots := config.connections
+	if slots == nil {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// concurrentTasks 返回同时下载的分P/视频数量，交互模式下只能逐个进行
+func concurrentTasks(config *Config) int {
+	if config.Interactive || config.ConcurrentTasks < 1 {
+		return 1
+	}
+	return config.ConcurrentTasks
+}
+
+// runConcurrent 以最多n个并发执行count个任务，返回每个任务的错误
+// 任一任务触发风控后不再启动新任务，避免继续请求加重风控，跳过的任务返回风控错误
+func runConcurrent(n, count int, task func(i int) error) []error {
+	errs := make([]error, count)
+	var stopped atomic.Bool
+	run := func(i int) {
+		if stopped.Load() {
+			errs[i] = fmt.Errorf("因风控跳过: %w", ErrRiskControl)
+			return
+		}
+		errs[i] = task(i)
+		if errors.Is(errs[i], ErrRiskControl) && !stopped.Swap(true) {
+			fmt.Printf("触发风控，停止剩余任务\n")
+		}
+	}
+
+	if n <= 1 {
+		for i := 0; i < count; i++ {
+			run(i)
+		}
+		return errs
+	}
+
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
@@ -1,5 +1,11 @@
 package core
 
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
 // Config 应用配置
 type Config struct {
 	// API选项
@@ -12,6 +18,27 @@ type Config struct {
 	EncodingPriority []string `json:"encodingPriority"`
 	QualityPriority  []string `json:"qualityPriority"`
 
+	// 轨道选择限制，0表示不限制
+	MaxResolution int   `json:"maxResolution"` // 最大分辨率（短边像素，如1080）
+	MinResolution int   `json:"minResolution"` // 最小分辨率（短边像素）
+	MaxFPS        int   `json:"maxFps"`        // 最大帧率
+	MaxBitrate    int   `json:"maxBitrate"`    // 最大码率(kbps)
+	MaxFileSize   int64 `json:"maxFileSize"`   // 最大文件大小(字节)，仅对已知大小的轨道生效
+
+	// 音频偏好: hires, dolby, aac-highest, aac-lowest，为空时在杜比和AAC中选择最高码率，Hi-Res需要指定hires
+	AudioPreference string `json:"audioPreference"`
+
+	// 输出格式
+	Container   string `json:"container"`   // 混流容器: mp4, mkv, mov，为空时根据音频编码自动选择
+	AudioFormat string `json:"audioFormat"` // 音频格式: m4a, flac, mp3, opus，为空时保持原编码
+
+	// 清单导出，启用后只生成清单不下载
+	ExportMPD bool `json:"exportMpd"` // 生成DASH MPD清单
+	ExportHLS bool `json:"exportHls"` // 生成HLS播放列表
+
+	// 互动视频剧情图导出格式: json, dot，为空时不导出
+	SteinGraph string `json:"steinGraph"`
+
 	// 显示选项
 	OnlyShowInfo bool `json:"onlyShowInfo"`
 	ShowAll      bool `json:"showAll"`
@@ -20,35 +47,56 @@ type Config struct {
 	Debug        bool `json:"debug"`
 
 	// 下载选项
-	UseAria2c       bool `json:"useAria2c"`
-	MultiThread     bool `json:"multiThread"`
-	SimplyMux       bool `json:"simplyMux"`
-	VideoOnly       bool `json:"videoOnly"`
-	AudioOnly       bool `json:"audioOnly"`
-	DanmakuOnly     bool `json:"danmakuOnly"`
-	CoverOnly       bool `json:"coverOnly"`
-	SubOnly         bool `json:"subOnly"`
-	SkipMux         bool `json:"skipMux"`
-	SkipSubtitle    bool `json:"skipSubtitle"`
-	SkipCover       bool `json:"skipCover"`
-	SkipAI          bool `json:"skipAi"`
-	DownloadDanmaku bool `json:"downloadDanmaku"`
+	UseAria2c       bool   `json:"useAria2c"`
+	MultiThread     bool   `json:"multiThread"`
+	SimplyMux       bool   `json:"simplyMux"`
+	FastStart       bool   `json:"fastStart"` // 内置混流器将moov放在文件开头
+	VideoOnly       bool   `json:"videoOnly"`
+	AudioOnly       bool   `json:"audioOnly"`
+	DanmakuOnly     bool   `json:"danmakuOnly"`
+	CoverOnly       bool   `json:"coverOnly"`
+	SubOnly         bool   `json:"subOnly"`
+	SkipMux         bool   `json:"skipMux"`
+	TimeRange       string `json:"timeRange"` // 只下载的时间范围，如 00:10:00-00:15:30
+	KeepAudio       bool   `json:"keepAudio"` // 混流后单独保留音频文件
+	SkipSubtitle    bool   `json:"skipSubtitle"`
+	SkipCover       bool   `json:"skipCover"`
+	SkipAI          bool   `json:"skipAi"`
+	DownloadDanmaku bool   `json:"downloadDanmaku"`
+
+	// 并发选项
+	ConcurrentTasks int `json:"concurrentTasks"` // 同时下载的分P/视频数量
+	MaxConnections  int `json:"maxConnections"`  // 同一次下载中所有任务共享的连接数上限
 
 	// 排序选项
 	VideoAscending bool `json:"videoAscending"`
 	AudioAscending bool `json:"audioAscending"`
 
 	// 网络选项
-	AllowPCDN        bool `json:"allowPcdn"`
-	ForceHTTP        bool `json:"forceHttp"`
-	ForceReplaceHost bool `json:"forceReplaceHost"`
+	AllowPCDN        bool          `json:"allowPcdn"`
+	ForceHTTP        bool          `json:"forceHttp"`
+	ForceReplaceHost bool          `json:"forceReplaceHost"`
+	Insecure         bool          `json:"insecure"`        // 跳过TLS证书校验
+	ConnectTimeout   time.Duration `json:"connectTimeout"`  // 连接超时，0表示使用默认值
+	ResponseTimeout  time.Duration `json:"responseTimeout"` // 等待响应头超时，0表示使用默认值
+	APITimeout       time.Duration `json:"apiTimeout"`      // API请求总超时，0表示使用默认值
+
+	// Transport 自定义HTTP传输，用于测试和回放，为nil时使用默认传输
+	Transport http.RoundTripper `json:"-"`
+	// BaseURLs 按主机名替换接口地址，如 "api.bilibili.com" -> "http://127.0.0.1:8080"
+	BaseURLs map[string]string `json:"baseUrls"`
+	// RecordDir 将API请求和响应录制到该目录，ReplayDir 从该目录回放，不访问网络
+	RecordDir string `json:"recordDir"`
+	ReplayDir string `json:"replayDir"`
 
 	// 文件和路径
 	FilePattern      string `json:"filePattern"`
 	MultiFilePattern string `json:"multiFilePattern"`
 	SelectPage       string `json:"selectPage"`
 	Language         string `json:"language"`
-	WorkDir          string `json:"workDir"`
+	DownloadArchive  string `json:"downloadArchive"` // 下载记录文件，已记录的分P会被跳过
+	WorkDir          string `json:"workDir"`         // 输出目录，为空时使用当前目录
+	TempDir          string `json:"tempDir"`         // 临时文件目录，为空时使用工作目录
 
 	// 认证
 	UserAgent   string `json:"userAgent"`
@@ -62,12 +110,23 @@ type Config struct {
 	Aria2cPath string `json:"aria2cPath"`
 
 	// API主机
-	UposHost     string `json:"uposHost"`
-	DelayPerPage string `json:"delayPerPage"`
-	Host         string `json:"host"`
-	EpHost       string `json:"epHost"`
-	TvHost       string `json:"tvHost"`
-	Area         string `json:"area"`
+	UposHost     string  `json:"uposHost"`
+	DelayPerPage string  `json:"delayPerPage"` // 分P之间的间隔，纯数字按秒计算，也支持 500ms、2s 等格式
+	APIRateLimit float64 `json:"apiRateLimit"` // 每秒最多发起的API请求数，0表示不限制
+	Host         string  `json:"host"`
+	EpHost       string  `json:"epHost"`
+	TvHost       string  `json:"tvHost"`
+	Area         string  `json:"area"`
+
+	// 运行时状态
+	clientOnce  sync.Once
+	client      *HTTPClient
+	connections chan struct{} // 下载连接名额，由DownloadWithClient创建
+	limiterOnce sync.Once
+	pageLimiter *rateLimiter // 分P之间的下载间隔，同一配置只创建一次，批量下载的多个链接共用
+	archiveOnce sync.Once
+	archive     *downloadArchive
+	archiveErr  error
 }
 
 // DefaultConfig 返回默认配置
@@ -87,16 +146,20 @@ func DefaultConfig() *Config {
 		UseAria2c:        false,
 		MultiThread:      true,
 		SimplyMux:        false,
+		FastStart:        true,
 		VideoOnly:        false,
 		AudioOnly:        false,
 		DanmakuOnly:      false,
 		CoverOnly:        false,
 		SubOnly:          false,
 		SkipMux:          false,
+		KeepAudio:        false,
 		SkipSubtitle:     false,
 		SkipCover:        false,
 		SkipAI:           true,
 		DownloadDanmaku:  false,
+		ConcurrentTasks:  1,
+		MaxConnections:   16,
 		VideoAscending:   false,
 		AudioAscending:   false,
 		AllowPCDN:        false,
@@ -106,7 +169,9 @@ func DefaultConfig() *Config {
 		MultiFilePattern: "<videoTitle>/[P<pageNumberWithZero>]<pageTitle>",
 		SelectPage:       "",
 		Language:         "",
+		DownloadArchive:  "",
 		WorkDir:          "",
+		TempDir:          "",
 		UserAgent:        "",
 		Cookie:           "",
 		AccessToken:      "",
@@ -116,6 +181,7 @@ func DefaultConfig() *Config {
 		Aria2cPath:       "",
 		UposHost:         "",
 		DelayPerPage:     "0",
+		APIRateLimit:     defaultAPIRateLimit,
 		Host:             "api.bilibili.com",
 		EpHost:           "api.bilibili.com",
 		TvHost:           "api.snm0516.aisee.tv",
@@ -123,28 +189,28 @@ func DefaultConfig() *Config {
 	}
 }
 
-// QualityMap 质量映射表
-var QualityMap = map[string]string{
-	"127": "8K 超高清",
-	"126": "杜比视界",
-	"125": "HDR 真彩",
-	"120": "4K 超清",
-	"116": "1080P 高帧率",
-	"112": "1080P 高码率",
-	"100": "智能修复",
-	"80":  "1080P 高清",
-	"74":  "720P 高帧率",
-	"64":  "720P 高清",
-	"48":  "720P 高清",
-	"32":  "480P 清晰",
-	"16":  "360P 流畅",
-	"5":   "144P 流畅",
-	"6":   "240P 流畅",
-}
-
-// EncodingMap 编码映射表
-var EncodingMap = map[string]string{
-	"hevc": "HEVC",
-	"av1":  "AV1",
-	"avc":  "AVC",
+// httpClient 返回本次运行共享的HTTP客户端，未通过DownloadWithClient注入时按配置创建
+func httpClient(config *Config) *HTTPClient {
+	config.clientOnce.Do(func() {
+		if config.client != nil {
+			return
+		}
+		maxConns := config.MaxConnections
+		if maxConns <= 0 {
+			maxConns = defaultMaxConnections
+		}
+		config.client = NewHTTPClientWithOptions(HTTPOptions{
+			Insecure:              config.Insecure,
+			ConnectTimeout:        config.ConnectTimeout,
+			ResponseHeaderTimeout: config.ResponseTimeout,
+			APITimeout:            config.APITimeout,
+			MaxConnsPerHost:       maxConns,
+			Transport:             config.Transport,
+			BaseURLs:              config.BaseURLs,
+			RecordDir:             config.RecordDir,
+			ReplayDir:             config.ReplayDir,
+			Cookie:                config.Cookie,
+		})
+	})
+	return config.client
 }
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tekintian/go-bbdown/util"
+)
+
+// 输出容器
+const (
+	ContainerMP4 = "mp4"
+	ContainerMKV = "mkv"
+	ContainerMOV = "mov"
+)
+
+// Containers 支持的输出容器
+var Containers = []string{ContainerMP4, ContainerMKV, ContainerMOV}
+
+// 仅音频时的输出格式
+const (
+	AudioFormatM4A  = "m4a"
+	AudioFormatFLAC = "flac"
+	AudioFormatMP3  = "mp3"
+	AudioFormatOpus = "opus"
+)
+
+// AudioFormats 支持的音频输出格式
+var AudioFormats = []string{AudioFormatM4A, AudioFormatFLAC, AudioFormatMP3, AudioFormatOpus}
+
+// audioFormat 音频输出格式的编码要求
+type audioFormat struct {
+	Entries []string // 可以直接复制的样本描述类型
+	Encoder []string // 需要转码时的FFmpeg编码参数
+}
+
+// audioFormatTable 各音频输出格式的编码要求
+var audioFormatTable = map[string]audioFormat{
+	AudioFormatM4A:  {Entries: []string{"mp4a", "ec-3", "ac-3"}, Encoder: []string{"-c:a", "aac", "-b:a", "320k"}},
+	AudioFormatFLAC: {Entries: []string{"fLaC"}, Encoder: []string{"-c:a", "flac"}},
+	AudioFormatMP3:  {Entries: []string{".mp3", "mp3 "}, Encoder: []string{"-c:a", "libmp3lame", "-q:a", "0"}},
+	AudioFormatOpus: {Entries: []string{"Opus"}, Encoder: []string{"-c:a", "libopus", "-b:a", "192k"}},
+}
+
+// sampleEntryFormats 样本描述类型对应的无损输出格式
+var sampleEntryFormats = map[string]string{
+	"mp4a": AudioFormatM4A,
+	"ec-3": AudioFormatM4A,
+	"ac-3": AudioFormatM4A,
+	"fLaC": AudioFormatFLAC,
+	"Opus": AudioFormatOpus,
+	".mp3": AudioFormatMP3,
+	"mp3 ": AudioFormatMP3,
+}
+
+// audioPlan 音频保存方式
+type audioPlan struct {
+	Format  string   // 输出格式，同时作为扩展名
+	Entry   string   // 源文件的样本描述类型
+	Encoder []string // 为空时无损复制
+}
+
+// planAudio 根据源文件的实际编码和期望格式确定音频保存方式
+// format为空时保持原编码，选择对应的扩展名
+func planAudio(srcPath, format string) audioPlan {
+	entry, err := probeSampleEntry(srcPath)
+	if err != nil {
+		fmt.Printf("无法识别音频编码，按AAC处理: %v\n", err)
+		entry = "mp4a"
+	}
+
+	plan := audioPlan{Format: sampleEntryFormats[entry], Entry: entry}
+	if plan.Format == "" {
+		plan.Format = AudioFormatM4A
+	}
+	if format == "" || format == plan.Format {
+		return plan
+	}
+
+	target := audioFormatTable[format]
+	plan.Format = format
+	if !containsEntry(target.Entries, entry) {
+		plan.Encoder = target.Encoder
+	}
+	return plan
+}
+
+// saveAudio 将音频保存为baseName加上对应的扩展名，返回最终路径，move为true时移动源文件
+// 下载的音频流都是MP4封装，M4A可直接使用，其他格式通过FFmpeg提取或转码
+func saveAudio(srcPath, baseName string, config *Config, move bool) (string, error) {
+	plan := planAudio(srcPath, config.AudioFormat)
+	dstPath := baseName + "." + plan.Format
+
+	if plan.Format == AudioFormatM4A && len(plan.Encoder) == 0 {
+		if move {
+			return dstPath, util.MoveFile(srcPath, dstPath)
+		}
+		return dstPath, util.CopyFile(srcPath, dstPath)
+	}
+
+	cmd := []string{ffmpegPath(config), "-i", srcPath, "-vn"}
+	if len(plan.Encoder) > 0 {
+		fmt.Printf("正在将%s转码为%s...\n", plan.Entry, plan.Format)
+		cmd = append(cmd, plan.Encoder...)
+	} else {
+		cmd = append(cmd, "-c:a", "copy")
+	}
+	cmd = append(cmd, dstPath, "-y")
+	if err := executeCommand(cmd); err != nil {
+		return "", err
+	}
+	if move {
+		return dstPath, os.Remove(srcPath)
+	}
+	return dstPath, nil
+}
+
+// muxContainer 确定混流输出容器的扩展名
+// 未指定容器时根据音频的实际编码选择，FLAC音轨使用MKV，其余使用MP4
+func muxContainer(audioPaths []string, config *Config) string {
+	if config.Container != "" {
+		return "." + config.Container
+	}
+	for _, audioPath := range audioPaths {
+		if entry, err := probeSampleEntry(audioPath); err == nil && entry == "fLaC" {
+			return "." + ContainerMKV
+		}
+	}
+	return "." + ContainerMP4
+}
+
+// rawAudioExtension 返回原始音频流的扩展名
+// 原始流为MP4封装，AAC和杜比音频使用.m4a，FLAC和Opus等使用.mp4
+func rawAudioExtension(path string) string {
+	entry, err := probeSampleEntry(path)
+	if err != nil || containsEntry(audioFormatTable[AudioFormatM4A].Entries, entry) {
+		return ".m4a"
+	}
+	return ".mp4"
+}
+
+// isMP4Container 判断输出路径是否为MP4系列容器
+func isMP4Container(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mov":
+		return true
+	}
+	return false
+}
+
+// containsEntry 判断样本描述类型是否在列表中
+func containsEntry(entries []string, entry string) bool {
+	for _, e := range entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,7 +15,6 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/tekintian/go-bbdown/util"
 )
@@ -27,6 +27,32 @@ type progressInfo struct {
 
 // Download 下载视频
 func Download(url string, config *Config) error {
+	return DownloadWithClient(url, config, httpClient(config))
+}
+
+// DownloadWithClient 使用指定的HTTP客户端下载视频，同一配置的后续请求都复用该客户端
+func DownloadWithClient(url string, config *Config, client *HTTPClient) error {
+	// 标记客户端已初始化，避免之后按配置重新创建
+	config.clientOnce.Do(func() {})
+	config.client = client
+	// 注入的客户端没有Cookie时使用配置中的Cookie
+	if client.Cookie == "" {
+		client.Cookie = config.Cookie
+	}
+
+	if err := configureRateLimits(config); err != nil {
+		return err
+	}
+	configureConnections(config)
+	if config.TimeRange != "" {
+		if _, _, err := ParseTimeRange(config.TimeRange); err != nil {
+			return err
+		}
+	}
+	if config.SteinGraph != "" && config.SteinGraph != "json" && config.SteinGraph != "dot" {
+		return fmt.Errorf("不支持的剧情图格式: %s，可选 json 或 dot", config.SteinGraph)
+	}
+
 	// 提取视频ID
 	id, err := util.ExtractVideoID(url)
 	if err != nil {
@@ -47,18 +73,11 @@ func Download(url string, config *Config) error {
 		return err
 	}
 
-	// 如果只需要信息，直接返回
-	if config.OnlyShowInfo {
-		fmt.Printf("视频信息：\n")
-		fmt.Printf("标题：%s\n", vinfo.Title)
-		fmt.Printf("UP主：%s\n", vinfo.Owner.Name)
-		fmt.Printf("播放量：%d\n", vinfo.Stat.View)
-		fmt.Printf("弹幕数：%d\n", vinfo.Stat.Danmaku)
-		fmt.Printf("评论数：%d\n", vinfo.Stat.Reply)
-		fmt.Printf("点赞数：%d\n", vinfo.Stat.Like)
-		fmt.Printf("硬币数：%d\n", vinfo.Stat.Coin)
-		fmt.Printf("收藏数：%d\n", vinfo.Stat.Favorite)
-		return nil
+	// 互动视频的分P只有根节点，遍历剧情图获取所有节点
+	if vinfo.IsSteinGate {
+		if err := expandSteinGate(vinfo, config); err != nil {
+			return err
+		}
 	}
 
 	// 选择分P
@@ -73,184 +92,68 @@ func Download(url string, config *Config) error {
 		selectedPages = vinfo.Pages
 	}
 
-	// 下载每个分P
-	for _, page := range selectedPages {
-		fmt.Printf("正在下载分P：%s\n", page.Part)
-
-		// 提取音视频轨道
-		parser := NewParser(config)
-		aidStr := fmt.Sprintf("%d", vinfo.Aid)
-		cidStr := fmt.Sprintf("%d", page.Cid)
-		tracks, err := parser.ExtractTracks("", aidStr, aidStr, cidStr, "", config.UseTVApi, config.UseIntlApi, config.UseAppApi, "")
-		if err != nil {
-			return err
-		}
-
-		// 选择轨道
-		var selectedVideoTrack *Track
-		var selectedAudioTrack *Track
-		var videoPath, audioPath string // 移到这里以扩大作用域
+	// 如果只需要信息，直接返回
+	if config.OnlyShowInfo {
+		fmt.Printf("视频信息：\n")
+		fmt.Printf("标题：%s\n", vinfo.Title)
+		fmt.Printf("UP主：%s\n", vinfo.Owner.Name)
+		fmt.Printf("播放量：%d\n", vinfo.Stat.View)
+		fmt.Printf("弹幕数：%d\n", vinfo.Stat.Danmaku)
+		fmt.Printf("评论数：%d\n", vinfo.Stat.Reply)
+		fmt.Printf("点赞数：%d\n", vinfo.Stat.Like)
+		fmt.Printf("硬币数：%d\n", vinfo.Stat.Coin)
+		fmt.Printf("收藏数：%d\n", vinfo.Stat.Favorite)
 
-		// 处理视频轨道选择
-		if !config.AudioOnly {
-			selectedVideoTrack, err = selectVideoTrack(tracks, config)
-			if err != nil {
-				return err
+		// 列出每个分P的可用流
+		if !config.HideStreams {
+			parser := NewParser(config)
+			aidStr := fmt.Sprintf("%d", vinfo.Aid)
+			for _, page := range selectedPages {
+				fmt.Printf("\nP%d：%s\n", page.Index, page.Part)
+				tracks, err := parser.ExtractTracks("", aidStr, aidStr, fmt.Sprintf("%d", page.Cid), "", config.UseTVApi, config.UseIntlApi, config.UseAppApi, "")
+				if err != nil {
+					fmt.Printf("获取可用流失败：%v\n", err)
+					continue
+				}
+				printTracks(tracks)
 			}
 		}
+		return nil
+	}
 
-		// 处理音频轨道选择
-		if !config.VideoOnly {
-			selectedAudioTrack, err = selectAudioTrack(tracks, config)
-			if err != nil {
-				return err
-			}
+	// 下载每个分P，可同时下载多个
+	errs := runConcurrent(concurrentTasks(config), len(selectedPages), func(i int) error {
+		page := selectedPages[i]
+		task := pageTask{
+			Aid:    vinfo.Aid,
+			Cid:    page.Cid,
+			Bvid:   vinfo.Bvid,
+			Title:  vinfo.Title,
+			Part:   page.Part,
+			Index:  page.Index,
+			MultiP: len(vinfo.Pages) > 1,
 		}
-
-		// 下载轨道
-		if selectedVideoTrack != nil {
-			fmt.Printf("正在下载视频：%s\n", selectedVideoTrack.Description) // 将QualityDesc改为Description
-			videoPath = fmt.Sprintf("%s_video.mp4", page.Part)
-			err = downloadTrack(selectedVideoTrack, videoPath, config)
-			if err != nil {
-				return err
-			}
+		// 番剧每集有独立的aid和bvid
+		if page.Aid != 0 {
+			task.Aid = page.Aid
+			task.Bvid = page.Bvid
 		}
 
-		if selectedAudioTrack != nil {
-			fmt.Printf("正在下载音频：%s\n", selectedAudioTrack.Codec)
-			audioPath = fmt.Sprintf("%s_audio.mp4", page.Part)
-			err = downloadTrack(selectedAudioTrack, audioPath, config)
-			if err != nil {
-				return err
-			}
+		exportOnly := config.ExportMPD || config.ExportHLS
+		if !exportOnly {
+			fmt.Printf("正在下载分P：%s\n", page.Part)
 		}
-
-		// 混流
-		if selectedVideoTrack != nil && selectedAudioTrack != nil {
-			fmt.Printf("正在混流...\n")
-			// 生成输出文件名
-			fileName := page.Part
-			if fileName == "" {
-				// 如果分P标题为空，使用视频标题
-				fileName = vinfo.Title
-			}
-
-			// 如果有多个分P，在文件名中添加序号
-			if len(vinfo.Pages) > 1 {
-				fileName = fmt.Sprintf("%s_P%d", fileName, page.Index)
-			}
-
-			// 清理文件名中的非法字符
-			fileName = strings.ReplaceAll(fileName, "/", "_")
-			fileName = strings.ReplaceAll(fileName, "\\", "_")
-			fileName = strings.ReplaceAll(fileName, ":", "_")
-			fileName = strings.ReplaceAll(fileName, "*", "_")
-			fileName = strings.ReplaceAll(fileName, "?", "_")
-			fileName = strings.ReplaceAll(fileName, "\"", "_")
-			fileName = strings.ReplaceAll(fileName, "<", "_")
-			fileName = strings.ReplaceAll(fileName, ">", "_")
-			fileName = strings.ReplaceAll(fileName, "|", "_")
-
-			// 混流输出文件
-			videoOutputPath := fmt.Sprintf("%s.mp4", fileName)
-			err = muxTracks(selectedVideoTrack, selectedAudioTrack, videoPath, audioPath, videoOutputPath, config)
-			if err != nil {
-				fmt.Printf("混流失败: %v\n", err)
-			} else {
-				fmt.Printf("混流完成: %s\n", videoOutputPath)
-			}
-
-			// 单独保存音频文件
-			if selectedAudioTrack != nil {
-				// 根据音频编码确定文件扩展名
-				audioExt := ".m4a" // 默认扩展名
-				if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "mp3") {
-					audioExt = ".mp3"
-				} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "aac") {
-					audioExt = ".aac"
-				} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "opus") {
-					audioExt = ".opus"
-				} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "flac") {
-					audioExt = ".flac"
-				}
-
-				audioOutputPath := fmt.Sprintf("%s%s", fileName, audioExt)
-
-				// 复制音频文件到最终位置
-				err = util.CopyFile(audioPath, audioOutputPath)
-				if err != nil {
-					fmt.Printf("保存音频文件失败: %v\n", err)
-				} else {
-					fmt.Printf("音频已保存: %s\n", audioOutputPath)
-				}
-			}
-
-			// 删除临时文件
-			if !config.SimplyMux {
-				if videoPath != "" {
-					if err := os.Remove(videoPath); err != nil {
-						fmt.Printf("删除临时视频文件失败: %v\n", err)
-					}
-				}
-				if audioPath != "" {
-					if err := os.Remove(audioPath); err != nil {
-						fmt.Printf("删除临时音频文件失败: %v\n", err)
-					}
-				}
-			}
-		} else if selectedAudioTrack != nil && selectedVideoTrack == nil {
-			// 只有音频，直接重命名为正确的音频格式
-			fmt.Printf("仅下载音频，重命名文件...\n")
-			// 生成输出文件名
-			fileName := page.Part
-			if fileName == "" {
-				fileName = vinfo.Title
-			}
-
-			// 如果有多个分P，在文件名中添加序号
-			if len(vinfo.Pages) > 1 {
-				fileName = fmt.Sprintf("%s_P%d", fileName, page.Index)
-			}
-
-			// 清理文件名中的非法字符
-			fileName = strings.ReplaceAll(fileName, "/", "_")
-			fileName = strings.ReplaceAll(fileName, "\\", "_")
-			fileName = strings.ReplaceAll(fileName, ":", "_")
-			fileName = strings.ReplaceAll(fileName, "*", "_")
-			fileName = strings.ReplaceAll(fileName, "?", "_")
-			fileName = strings.ReplaceAll(fileName, "\"", "_")
-			fileName = strings.ReplaceAll(fileName, "<", "_")
-			fileName = strings.ReplaceAll(fileName, ">", "_")
-			fileName = strings.ReplaceAll(fileName, "|", "_")
-
-			// 根据音频编码确定文件扩展名
-			audioExt := ".m4a" // 默认扩展名
-			if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "mp3") {
-				audioExt = ".mp3"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "aac") {
-				audioExt = ".aac"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "opus") {
-				audioExt = ".opus"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "flac") {
-				audioExt = ".flac"
-			}
-
-			audioOutputPath := fmt.Sprintf("%s%s", fileName, audioExt)
-			
-			// 重命名文件
-			err := os.Rename(audioPath, audioOutputPath)
-			if err != nil {
-				fmt.Printf("重命名音频文件失败: %v\n", err)
-			} else {
-				fmt.Printf("音频已保存: %s\n", audioOutputPath)
-			}
+		if err := downloadPage(task, config); err != nil {
+			return fmt.Errorf("P%d %s: %w", page.Index, page.Part, err)
 		}
 
-		fmt.Printf("分P下载完成：%s\n", page.Part)
-	}
+		if !exportOnly {
+			fmt.Printf("分P下载完成：%s\n", page.Part)
+		}
+		return nil
+	})
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // fetchVideoInfo 获取视频信息
@@ -315,24 +218,16 @@ func fetchVideoInfo(id string, config *Config) (*VInfo, error) {
 	}
 
 	// 创建HTTP客户端
-	client := NewHTTPClient()
+	client := httpClient(config)
 
-	// 构建API URL
+	// 构建API URL，需要WBI签名的接口在发送时签名
+	wbiSigned := false
 	switch idType {
 	case "bv", "av":
-		prefix := fmt.Sprintf("https://%s/x/web-interface/view?", config.Host)
-
-		// 添加WBI签名
-		wbiKey, err := GetWBIKey(client)
-		if err != nil {
-			return nil, fmt.Errorf("获取WBI密钥失败: %w", err)
-		}
-		queryString := buildQueryStringHTTP(params)
-		api = fmt.Sprintf("%s%s&w_rid=%s", prefix, queryString, util.WBISign(queryString, wbiKey))
-	case "ep":
-		api = fmt.Sprintf("https://%s/pgc/view/web/season?%s", config.EpHost, buildQueryStringHTTP(params))
-	case "ss":
-		api = fmt.Sprintf("https://%s/pgc/view/web/season?%s", config.EpHost, buildQueryStringHTTP(params))
+		api = client.apiURL(config.Host, "/x/web-interface/view?")
+		wbiSigned = true
+	case "ep", "ss":
+		api = client.apiURL(config.EpHost, "/pgc/view/web/season?"+buildQueryStringHTTP(params))
 	}
 
 	if config.Debug {
@@ -340,7 +235,13 @@ func fetchVideoInfo(id string, config *Config) (*VInfo, error) {
 	}
 
 	// 发送请求
-	resp, err := client.GetWebSource(api, config.UserAgent)
+	var resp string
+	var err error
+	if wbiSigned {
+		resp, err = client.GetWBISource(api, params, config.UserAgent)
+	} else {
+		resp, err = client.GetWebSource(api, config.UserAgent)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -349,6 +250,11 @@ func fetchVideoInfo(id string, config *Config) (*VInfo, error) {
 		fmt.Printf("Debug: Response: %s\n", resp)
 	}
 
+	// 番剧接口的数据在result字段中，每集对应一个分P
+	if idType == "ep" || idType == "ss" {
+		return parseBangumiSeason(api, resp)
+	}
+
 	// 解析响应 - API返回的数据在data字段中
 	var response struct {
 		Code    int    `json:"code"`
@@ -362,12 +268,73 @@ func fetchVideoInfo(id string, config *Config) (*VInfo, error) {
 	}
 
 	if response.Code != 0 {
-		return nil, fmt.Errorf("API错误: %s", response.Message)
+		return nil, newAPIError(api, response.Code, response.Message)
 	}
+	response.Data.IsSteinGate = response.Data.Rights.IsSteinGate == 1
 
 	return &response.Data, nil
 }
 
+// parseBangumiSeason 解析番剧接口返回的剧集信息，每集转换为一个分P
+func parseBangumiSeason(api, resp string) (*VInfo, error) {
+	var response struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Result  struct {
+			SeasonID int64  `json:"season_id"`
+			Title    string `json:"title"`
+			Cover    string `json:"cover"`
+			Evaluate string `json:"evaluate"`
+			Episodes []struct {
+				ID        int64  `json:"id"`
+				Aid       int64  `json:"aid"`
+				Bvid      string `json:"bvid"`
+				Cid       int64  `json:"cid"`
+				Title     string `json:"title"`
+				LongTitle string `json:"long_title"`
+				Duration  int    `json:"duration"` // 毫秒
+			} `json:"episodes"`
+		} `json:"result"`
+	}
+
+	if err := parseJSON(resp, &response); err != nil {
+		return nil, err
+	}
+	if response.Code != 0 {
+		return nil, newAPIError(api, response.Code, response.Message)
+	}
+
+	result := response.Result
+	vinfo := &VInfo{
+		Title:      result.Title,
+		Desc:       result.Evaluate,
+		Pic:        result.Cover,
+		IsBangumi:  true,
+		SeasonID:   fmt.Sprintf("%d", result.SeasonID),
+		SeasonName: result.Title,
+	}
+	for i, ep := range result.Episodes {
+		part := ep.Title
+		if ep.LongTitle != "" {
+			part = fmt.Sprintf("%s %s", ep.Title, ep.LongTitle)
+		}
+		vinfo.Pages = append(vinfo.Pages, Page{
+			Index: i + 1,
+			Aid:   ep.Aid,
+			Cid:   ep.Cid,
+			Bvid:  ep.Bvid,
+			Epid:  fmt.Sprintf("%d", ep.ID),
+			Title: ep.Title,
+			Part:  part,
+			Dur:   ep.Duration / 1000,
+		})
+	}
+	if len(vinfo.Pages) == 0 {
+		return nil, fmt.Errorf("番剧没有可下载的剧集")
+	}
+	return vinfo, nil
+}
+
 // parseSelectedPages 解析选择的分P
 func parseSelectedPages(selected string, pages []Page) ([]Page, error) { // 将[]*Page改为[]Page
 	// 解析选择的分P，支持格式：1,2-5,7
@@ -411,136 +378,50 @@ func parseSelectedPages(selected string, pages []Page) ([]Page, error) { // 将[
 	return selectedPages, nil
 }
 
-// selectVideoTrack 选择视频轨道
-func selectVideoTrack(tracks []*Track, config *Config) (*Track, error) {
-	// 过滤视频轨道
-	var videoTracks []*Track
-	for _, track := range tracks {
-		if track.FrameType == "video" {
-			videoTracks = append(videoTracks, track)
-		}
-	}
-
-	if len(videoTracks) == 0 {
-		return nil, fmt.Errorf("没有找到视频轨道")
-	}
-
-	// 如果是交互式选择
-	if config.Interactive {
-		// 显示所有可选轨道
-		fmt.Println("可用的视频轨道：")
-		for i, track := range videoTracks {
-			fmt.Printf("%d. %dx%d - %s - %s\n", i+1, track.Width, track.Height, track.Codec, formatSize(track.Size))
-		}
-
-		// 选择轨道
-		fmt.Print("请选择要下载的轨道：")
-		var choice int
-		fmt.Scanln(&choice)
-		if choice < 1 || choice > len(videoTracks) {
-			return nil, fmt.Errorf("无效的选择")
-		}
-		return videoTracks[choice-1], nil
-	}
-
-	// 按照质量优先级选择
-	if config.QualityPriority != nil && len(config.QualityPriority) > 0 {
-		for _, quality := range config.QualityPriority {
-			for _, track := range videoTracks {
-				if strings.Contains(track.Description, quality) {
-					return track, nil
-				}
-			}
-		}
+// downloadTrack 下载轨道
+func downloadTrack(track *Track, path string, config *Config) error {
+	// 创建目录
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
 	}
 
-	// 按照编码优先级选择
-	if config.EncodingPriority != nil && len(config.EncodingPriority) > 0 {
-		for _, encoding := range config.EncodingPriority {
-			for _, track := range videoTracks {
-				if strings.EqualFold(track.Codec, encoding) {
-					return track, nil
-				}
-			}
-		}
+	// 分段轨道需要逐段下载后拼接
+	if len(track.Segments) > 0 {
+		return downloadSegments(track, path, config)
 	}
 
-	// 默认选择最高质量
-	var bestTrack *Track
-	for _, track := range videoTracks {
-		if bestTrack == nil || track.Width > bestTrack.Width || track.Height > bestTrack.Height {
-			bestTrack = track
+	// 主地址失败时依次尝试备用地址
+	var lastErr error
+	for _, url := range trackURLs(track) {
+		if lastErr = downloadURL(url, path, config); lastErr == nil {
+			return nil
 		}
 	}
-
-	return bestTrack, nil
+	return lastErr
 }
 
-// selectAudioTrack 选择音频轨道
-func selectAudioTrack(tracks []*Track, config *Config) (*Track, error) {
-	// 过滤音频轨道
-	var audioTracks []*Track
-	for _, track := range tracks {
-		if track.FrameType == "audio" {
-			audioTracks = append(audioTracks, track)
-		}
-	}
-
-	if len(audioTracks) == 0 {
-		return nil, fmt.Errorf("没有找到音频轨道")
-	}
-
-	// 如果是交互式选择
-	if config.Interactive {
-		// 显示所有可选轨道
-		fmt.Println("可用的音频轨道：")
-		for i, track := range audioTracks {
-			fmt.Printf("%d. %s - %s - %s\n", i+1, track.Codec, track.Description, formatSize(track.Size))
-		}
-
-		// 选择轨道
-		fmt.Print("请选择要下载的轨道：")
-		var choice int
-		fmt.Scanln(&choice)
-		if choice < 1 || choice > len(audioTracks) {
-			return nil, fmt.Errorf("无效的选择")
-		}
-		return audioTracks[choice-1], nil
-	}
-
-	// 默认选择最高质量
-	var bestTrack *Track
-	for _, track := range audioTracks {
-		if bestTrack == nil || track.Bandwidth > bestTrack.Bandwidth {
-			bestTrack = track
-		}
-	}
-
-	return bestTrack, nil
+// trackURLs 返回轨道的主地址和备用地址
+func trackURLs(track *Track) []string {
+	return append([]string{track.URL}, track.BackupURLs...)
 }
 
-// downloadTrack 下载轨道
-func downloadTrack(track *Track, path string, config *Config) error {
-	// 创建目录
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
-	}
-
-	client := NewHTTPClient()
+// downloadURL 下载单个地址到指定路径
+func downloadURL(url, path string, config *Config) error {
+	client := httpClient(config)
 
 	// 使用aria2c
 	if config.UseAria2c {
-		return downloadWithAria2c(track.URL, path, config)
+		return downloadWithAria2c(url, path, config)
 	}
 
 	// 多线程下载
 	if config.MultiThread {
-		return multiThreadDownload(client, track.URL, path, config)
+		return multiThreadDownload(client, url, path, config)
 	}
 
 	// 单线程下载
-	return singleThreadDownload(client, track.URL, path, config)
+	return singleThreadDownload(client, url, path, config)
 }
 
 // multiThreadDownload 多线程下载
@@ -567,16 +448,18 @@ func multiThreadDownload(client *HTTPClient, url, filePath string, config *Confi
 	var totalDownloaded int64
 	var wg sync.WaitGroup
 
-	// 并发下载分段
-	for _, clip := range clips {
+	// 并发下载分段，失败的分段在下次运行时重新下载
+	clipErrs := make([]error, len(clips))
+	for i, clip := range clips {
 		wg.Add(1)
-		go func(c Clip) {
+		go func(i int, c Clip) {
 			defer wg.Done()
-			err := downloadClip(client, url, filePath, c, progress)
-			if err != nil {
-				fmt.Printf("下载分段 %d 失败: %v\n", c.Index, err)
+			release := acquireConnection(config)
+			defer release()
+			if err := downloadClip(client, url, filePath, c, progress); err != nil {
+				clipErrs[i] = fmt.Errorf("下载分段 %d 失败: %w", c.Index, err)
 			}
-		}(clip)
+		}(i, clip)
 	}
 
 	// 进度监控
@@ -592,6 +475,11 @@ func multiThreadDownload(client *HTTPClient, url, filePath string, config *Confi
 	close(progress)
 	fmt.Println() // 换行
 
+	// 已完成的分段保留在磁盘上，重新下载时跳过
+	if err := errors.Join(clipErrs...); err != nil {
+		return err
+	}
+
 	// 合并文件
 	if len(clips) > 1 {
 		return mergeClips(filePath, clips)
@@ -609,6 +497,9 @@ func multiThreadDownload(client *HTTPClient, url, filePath string, config *Confi
 
 // singleThreadDownload 单线程下载
 func singleThreadDownload(client *HTTPClient, url, filePath string, config *Config) error {
+	release := acquireConnection(config)
+	defer release()
+
 	fmt.Printf("开始下载: %s\n", url)
 
 	var lastProgress int64
@@ -715,8 +606,10 @@ func getAllClips(url string, fileSize int64) []Clip {
 }
 
 // downloadClip 下载单个分段
+// 分段先写入.part文件，完整下载后才重命名为.tmp，因此已存在的.tmp文件都是完整的
 func downloadClip(client *HTTPClient, url, filePath string, clip Clip, progress chan<- progressInfo) error {
 	tempPath := fmt.Sprintf("%s.%05d.tmp", filePath, clip.Index)
+	partPath := tempPath + ".part"
 
 	// 检查临时文件是否已存在
 	if info, err := os.Stat(tempPath); err == nil {
@@ -756,32 +649,21 @@ func downloadClip(client *HTTPClient, url, filePath string, clip Clip, progress
 	}
 
 	// 创建临时文件
-	file, err := os.Create(tempPath)
+	file, err := os.Create(partPath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	// 下载数据
-	buffer := make([]byte, 32*1024) // 32KB
-	var downloaded int64
-
-	for {
-		n, err := resp.Body.Read(buffer)
-		if n > 0 {
-			_, writeErr := file.Write(buffer[:n])
-			if writeErr != nil {
-				return writeErr
-			}
-			downloaded += int64(n)
-		}
-
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
+	downloaded, err := io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, tempPath); err != nil {
+		return err
 	}
 
 	progress <- progressInfo{downloaded: downloaded, total: downloaded}
@@ -805,13 +687,15 @@ func mergeClips(filePath string, clips []Clip) error {
 
 		_, err = io.Copy(outputFile, inputFile)
 		inputFile.Close()
-		os.Remove(tempPath) // 删除临时文件
-
 		if err != nil {
 			return err
 		}
 	}
 
+	// 全部合并成功后再删除分段，失败时可以重新合并
+	for _, clip := range clips {
+		os.Remove(fmt.Sprintf("%s.%05d.tmp", filePath, clip.Index))
+	}
 	return nil
 }
 
@@ -886,31 +770,57 @@ type Clip struct {
 	To    int64
 }
 
-// muxTracks 混流
-func muxTracks(videoTrack, audioTrack *Track, videoPath, audioPath, outputPath string, config *Config) error {
+// muxTracks 混流，支持多条音轨，有语言信息的音轨会写入语言标签
+// HDR和杜比视界视频会附加保留色彩元数据的参数，杜比视界在启用MP4Box时优先使用MP4Box
+func muxTracks(videoTrack *Track, audioTracks []*Track, videoPath string, audioPaths []string, outputPath string, config *Config) error {
+	container := strings.ToLower(filepath.Ext(outputPath))
+	if isDolbyVision(videoTrack) && config.UseMP4Box && container == ".mp4" {
+		// MP4Box导入时会完整保留dvcC/dvvC配置盒
+		if err := muxWithMP4Box(audioTracks, videoPath, audioPaths, outputPath, config); err == nil {
+			return nil
+		}
+	}
+
+	// 未安装FFmpeg时使用MP4Box或内置混流器
+	if !commandExists(ffmpegPath(config)) {
+		if config.UseMP4Box {
+			if err := muxWithMP4Box(audioTracks, videoPath, audioPaths, outputPath, config); err == nil {
+				return nil
+			}
+		}
+		fmt.Printf("未找到FFmpeg，使用内置混流器\n")
+		return muxNative(audioTracks, videoPath, audioPaths, outputPath, config)
+	}
+
 	// 使用FFmpeg混流
-	cmd := []string{
-		config.FFmpegPath,
-		"-i", videoPath,
-		"-i", audioPath,
-		"-c:v", "copy",
-		"-c:a", "copy",
-		outputPath,
-		"-y",
+	cmd := []string{ffmpegPath(config), "-i", videoPath}
+	for _, audioPath := range audioPaths {
+		cmd = append(cmd, "-i", audioPath)
+	}
+	cmd = append(cmd, "-map", "0:v")
+	for i := range audioPaths {
+		cmd = append(cmd, "-map", fmt.Sprintf("%d:a", i+1))
+	}
+	cmd = append(cmd, "-c:v", "copy", "-c:a", "copy")
+	cmd = append(cmd, hdrMuxArgs(videoTrack, container)...)
+
+	// 简单混流时不写入轨道标题和语言等元数据
+	if !config.SimplyMux {
+		cmd = append(cmd, hdrMetadataArgs(videoTrack)...)
+		for i, track := range audioTracks {
+			if track.Language != "" {
+				cmd = append(cmd, fmt.Sprintf("-metadata:s:a:%d", i), "language="+iso6392(track.Language))
+			}
+		}
 	}
+	cmd = append(cmd, outputPath, "-y")
 
 	// 执行命令
 	err := executeCommand(cmd)
 	if err != nil {
 		// 如果FFmpeg失败，尝试使用MP4Box
 		if config.UseMP4Box {
-			cmd := []string{
-				config.Mp4boxPath,
-				"-add", videoPath,
-				"-add", audioPath,
-				"-new", outputPath,
-			}
-			return executeCommand(cmd)
+			return muxWithMP4Box(audioTracks, videoPath, audioPaths, outputPath, config)
 		}
 		return err
 	}
@@ -918,6 +828,57 @@ func muxTracks(videoTrack, audioTrack *Track, videoPath, audioPath, outputPath s
 	return nil
 }
 
+// muxWithMP4Box 使用MP4Box混流
+func muxWithMP4Box(audioTracks []*Track, videoPath string, audioPaths []string, outputPath string, config *Config) error {
+	cmd := []string{mp4boxPath(config), "-add", videoPath}
+	for i, audioPath := range audioPaths {
+		if audioTracks[i].Language != "" && !config.SimplyMux {
+			audioPath += ":lang=" + iso6392(audioTracks[i].Language)
+		}
+		cmd = append(cmd, "-add", audioPath)
+	}
+	cmd = append(cmd, "-new", outputPath)
+	return executeCommand(cmd)
+}
+
+// muxNative 使用内置混流器合并DASH音视频流，仅支持MP4容器
+func muxNative(audioTracks []*Track, videoPath string, audioPaths []string, outputPath string, config *Config) error {
+	if !isMP4Container(outputPath) {
+		return fmt.Errorf("内置混流器不支持%s容器，请安装FFmpeg", filepath.Ext(outputPath))
+	}
+
+	inputs := append([]string{videoPath}, audioPaths...)
+	languages := make([]string, len(inputs))
+	for i, track := range audioTracks {
+		if track.Language != "" && !config.SimplyMux {
+			languages[i+1] = iso6392(track.Language)
+		}
+	}
+	return MuxMP4(inputs, outputPath, MP4MuxOptions{FastStart: config.FastStart, Languages: languages})
+}
+
+// commandExists 判断外部命令是否可用
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// ffmpegPath 返回FFmpeg可执行文件路径，未配置时使用PATH中的ffmpeg
+func ffmpegPath(config *Config) string {
+	if config.FFmpegPath != "" {
+		return config.FFmpegPath
+	}
+	return "ffmpeg"
+}
+
+// mp4boxPath 返回MP4Box可执行文件路径，未配置时使用PATH中的MP4Box
+func mp4boxPath(config *Config) string {
+	if config.Mp4boxPath != "" {
+		return config.Mp4boxPath
+	}
+	return "MP4Box"
+}
+
 // formatSize 格式化文件大小
 func formatSize(size int64) string {
 	if size < 1024 {
@@ -950,7 +911,7 @@ func parseJSON(data string, v interface{}) error {
 // downloadSeason 下载合集
 func downloadSeason(id string, config *Config) error {
 	seasonData := strings.TrimPrefix(id, "season:")
-	
+
 	var userID, seasonID string
 	if strings.Contains(seasonData, ":") {
 		// 新格式: season:用户ID:合集ID
@@ -987,40 +948,8 @@ func downloadSeason(id string, config *Config) error {
 	fmt.Printf("包含 %d 个视频\n", seasonInfo.TotalCount)
 
 	// 下载每个视频
-	for i, video := range seasonInfo.Videos {
-		fmt.Printf("\n[%d/%d] 下载视频：%s\n", i+1, len(seasonInfo.Videos), video.Title)
-
-		// 如果缺少aid或cid，先获取视频信息
-		if video.Aid == 0 || video.Cid == 0 {
-			vinfo, err := fetchVideoInfo(video.Bvid, config)
-			if err != nil {
-				fmt.Printf("获取视频信息失败：%s，错误：%v\n", video.Title, err)
-				continue
-			}
-			video.Aid = vinfo.Aid
-			if len(vinfo.Pages) > 0 {
-				video.Cid = vinfo.Pages[0].Cid
-			}
-		}
-
-		// 转换为SeasonVideo类型
-		seasonVideo := SeasonVideo{
-			Aid:      video.Aid,
-			Bvid:     video.Bvid,
-			Cid:      video.Cid,
-			Title:    video.Title,
-			Duration: video.Duration,
-			Cover:    video.Cover,
-			Index:    video.Index,
-			Part:     video.Part,
-		}
-
-		// 调用下载单个视频的函数
-		err := downloadSingleVideoByInfo(seasonVideo, config)
-		if err != nil {
-			fmt.Printf("下载视频失败：%s，错误：%v\n", video.Title, err)
-			continue
-		}
+	if err := downloadVideoList(seasonInfo.Videos, config); err != nil {
+		return fmt.Errorf("合集 %s 未完全下载: %w", seasonInfo.SeasonName, err)
 	}
 
 	fmt.Printf("\n合集下载完成：%s\n", seasonInfo.SeasonName)
@@ -1052,15 +981,31 @@ func downloadMediaList(id string, config *Config) error {
 	fmt.Printf("包含 %d 个视频\n", medialistInfo.TotalCount)
 
 	// 下载每个视频
+	videos := make([]SeasonVideo, len(medialistInfo.Videos))
 	for i, video := range medialistInfo.Videos {
-		fmt.Printf("\n[%d/%d] 下载视频：%s\n", i+1, len(medialistInfo.Videos), video.Title)
+		videos[i] = SeasonVideo(video)
+	}
+	if err := downloadVideoList(videos, config); err != nil {
+		return fmt.Errorf("媒体列表 %s 未完全下载: %w", medialistInfo.Title, err)
+	}
+
+	fmt.Printf("\n媒体列表下载完成：%s\n", medialistInfo.Title)
+	return nil
+}
+
+// downloadVideoList 下载合集或媒体列表中的视频，可同时下载多个，单个视频失败不影响其他视频
+// 返回所有失败视频的错误
+func downloadVideoList(videos []SeasonVideo, config *Config) error {
+	errs := runConcurrent(concurrentTasks(config), len(videos), func(i int) error {
+		video := videos[i]
+		fmt.Printf("\n[%d/%d] 下载视频：%s\n", i+1, len(videos), video.Title)
 
 		// 如果缺少aid或cid，先获取视频信息
 		if video.Aid == 0 || video.Cid == 0 {
 			vinfo, err := fetchVideoInfo(video.Bvid, config)
 			if err != nil {
 				fmt.Printf("获取视频信息失败：%s，错误：%v\n", video.Title, err)
-				continue
+				return fmt.Errorf("%s: %w", video.Title, err)
 			}
 			video.Aid = vinfo.Aid
 			if len(vinfo.Pages) > 0 {
@@ -1068,413 +1013,41 @@ func downloadMediaList(id string, config *Config) error {
 			}
 		}
 
-		// 转换为SeasonVideo类型
-		seasonVideo := SeasonVideo{
-			Aid:      video.Aid,
-			Bvid:     video.Bvid,
-			Cid:      video.Cid,
-			Title:    video.Title,
-			Duration: video.Duration,
-			Cover:    video.Cover,
-			Index:    video.Index,
-			Part:     video.Part,
-		}
-
 		// 调用下载单个视频的函数
-		err := downloadSingleVideoByInfo(seasonVideo, config)
-		if err != nil {
+		if err := downloadSingleVideoByInfo(video, config); err != nil {
 			fmt.Printf("下载视频失败：%s，错误：%v\n", video.Title, err)
-			continue
-		}
-	}
-
-	fmt.Printf("\n媒体列表下载完成：%s\n", medialistInfo.Title)
-	return nil
-}
-
-// downloadSingleVideo 下载单个视频（重用原有逻辑）
-func downloadSingleVideo(url string, config *Config) error {
-	// 提取视频ID
-	id, err := util.ExtractVideoID(url)
-	if err != nil {
-		return err
-	}
-
-	// 获取视频信息
-	vinfo, err := fetchVideoInfo(id, config)
-	if err != nil {
-		return err
-	}
-
-	// 选择分P
-	var selectedPages []Page
-	if config.SelectPage != "" {
-		selectedPages, err = parseSelectedPages(config.SelectPage, vinfo.Pages)
-		if err != nil {
-			return err
-		}
-	} else {
-		selectedPages = vinfo.Pages
-	}
-
-	// 下载每个分P
-	for _, page := range selectedPages {
-		fmt.Printf("正在下载分P：%s\n", page.Part)
-
-		// 提取音视频轨道
-		parser := NewParser(config)
-		aidStr := fmt.Sprintf("%d", vinfo.Aid)
-		cidStr := fmt.Sprintf("%d", page.Cid)
-		tracks, err := parser.ExtractTracks("", aidStr, aidStr, cidStr, "", config.UseTVApi, config.UseIntlApi, config.UseAppApi, "")
-		if err != nil {
-			return err
-		}
-
-		// 选择轨道
-		var selectedVideoTrack *Track
-		var selectedAudioTrack *Track
-
-		// 处理视频轨道选择
-		if !config.AudioOnly {
-			selectedVideoTrack, err = selectVideoTrack(tracks, config)
-			if err != nil {
-				return err
-			}
-		}
-
-		// 处理音频轨道选择
-		if !config.VideoOnly {
-			selectedAudioTrack, err = selectAudioTrack(tracks, config)
-			if err != nil {
-				return err
-			}
-		}
-
-		// 下载轨道
-		var videoPath, audioPath string
-		if selectedVideoTrack != nil {
-			fmt.Printf("正在下载视频：%s\n", selectedVideoTrack.Description)
-			videoPath = fmt.Sprintf("%s_video.mp4", page.Part)
-			err = downloadTrack(selectedVideoTrack, videoPath, config)
-			if err != nil {
-				return err
-			}
-		}
-
-		if selectedAudioTrack != nil {
-			fmt.Printf("正在下载音频：%s\n", selectedAudioTrack.Codec)
-			audioPath = fmt.Sprintf("%s_audio.mp4", page.Part)
-			err = downloadTrack(selectedAudioTrack, audioPath, config)
-			if err != nil {
-				return err
-			}
-		}
-
-		// 混流
-		if selectedVideoTrack != nil && selectedAudioTrack != nil {
-			fmt.Printf("正在混流...\n")
-			// 生成输出文件名
-			fileName := page.Part
-			if fileName == "" {
-				// 如果分P标题为空，使用视频标题
-				fileName = vinfo.Title
-			}
-
-			// 如果有多个分P，在文件名中添加序号
-			if len(vinfo.Pages) > 1 {
-				fileName = fmt.Sprintf("%s_P%d", fileName, page.Index)
-			}
-
-			// 清理文件名中的非法字符
-			fileName = strings.ReplaceAll(fileName, "/", "_")
-			fileName = strings.ReplaceAll(fileName, "\\", "_")
-			fileName = strings.ReplaceAll(fileName, ":", "_")
-			fileName = strings.ReplaceAll(fileName, "*", "_")
-			fileName = strings.ReplaceAll(fileName, "?", "_")
-			fileName = strings.ReplaceAll(fileName, "\"", "_")
-			fileName = strings.ReplaceAll(fileName, "<", "_")
-			fileName = strings.ReplaceAll(fileName, ">", "_")
-			fileName = strings.ReplaceAll(fileName, "|", "_")
-
-			// 混流输出文件
-			videoOutputPath := fmt.Sprintf("%s.mp4", fileName)
-			err = muxTracks(selectedVideoTrack, selectedAudioTrack, videoPath, audioPath, videoOutputPath, config)
-			if err != nil {
-				fmt.Printf("混流失败: %v\n", err)
-			} else {
-				fmt.Printf("混流完成: %s\n", videoOutputPath)
-			}
-
-			// 单独保存音频文件
-			if selectedAudioTrack != nil {
-				// 根据音频编码确定文件扩展名
-				audioExt := ".m4a" // 默认扩展名
-				if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "mp3") {
-					audioExt = ".mp3"
-				} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "aac") {
-					audioExt = ".aac"
-				} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "opus") {
-					audioExt = ".opus"
-				} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "flac") {
-					audioExt = ".flac"
-				}
-
-				audioOutputPath := fmt.Sprintf("%s%s", fileName, audioExt)
-
-				// 复制音频文件到最终位置
-				err = util.CopyFile(audioPath, audioOutputPath)
-				if err != nil {
-					fmt.Printf("保存音频文件失败: %v\n", err)
-				} else {
-					fmt.Printf("音频已保存: %s\n", audioOutputPath)
-				}
-			}
-
-			// 删除临时文件
-			if !config.SimplyMux {
-				if videoPath != "" {
-					if err := os.Remove(videoPath); err != nil {
-						fmt.Printf("删除临时视频文件失败: %v\n", err)
-					}
-				}
-				if audioPath != "" {
-					if err := os.Remove(audioPath); err != nil {
-						fmt.Printf("删除临时音频文件失败: %v\n", err)
-					}
-				}
-			}
-		} else if selectedAudioTrack != nil && selectedVideoTrack == nil {
-			// 只有音频，直接重命名为正确的音频格式
-			fmt.Printf("仅下载音频，重命名文件...\n")
-			// 生成输出文件名
-			fileName := page.Part
-			if fileName == "" {
-				fileName = vinfo.Title
-			}
-
-			// 如果有多个分P，在文件名中添加序号
-			if len(vinfo.Pages) > 1 {
-				fileName = fmt.Sprintf("%s_P%d", fileName, page.Index)
-			}
-
-			// 清理文件名中的非法字符
-			fileName = strings.ReplaceAll(fileName, "/", "_")
-			fileName = strings.ReplaceAll(fileName, "\\", "_")
-			fileName = strings.ReplaceAll(fileName, ":", "_")
-			fileName = strings.ReplaceAll(fileName, "*", "_")
-			fileName = strings.ReplaceAll(fileName, "?", "_")
-			fileName = strings.ReplaceAll(fileName, "\"", "_")
-			fileName = strings.ReplaceAll(fileName, "<", "_")
-			fileName = strings.ReplaceAll(fileName, ">", "_")
-			fileName = strings.ReplaceAll(fileName, "|", "_")
-
-			// 根据音频编码确定文件扩展名
-			audioExt := ".m4a" // 默认扩展名
-			if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "mp3") {
-				audioExt = ".mp3"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "aac") {
-				audioExt = ".aac"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "opus") {
-				audioExt = ".opus"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "flac") {
-				audioExt = ".flac"
-			}
-
-			audioOutputPath := fmt.Sprintf("%s%s", fileName, audioExt)
-			
-			// 重命名文件
-			err := os.Rename(audioPath, audioOutputPath)
-			if err != nil {
-				fmt.Printf("重命名音频文件失败: %v\n", err)
-			} else {
-				fmt.Printf("音频已保存: %s\n", audioOutputPath)
-			}
+			return fmt.Errorf("%s: %w", video.Title, err)
 		}
-
-		fmt.Printf("分P下载完成：%s\n", page.Part)
-	}
-
-	return nil
+		return nil
+	})
+	return errors.Join(errs...)
 }
 
 // downloadSingleVideoByInfo 通过视频信息下载单个视频
 func downloadSingleVideoByInfo(video SeasonVideo, config *Config) error {
-	// 提取音视频轨道
-	parser := NewParser(config)
-	aidStr := fmt.Sprintf("%d", video.Aid)
-	cidStr := fmt.Sprintf("%d", video.Cid)
-	tracks, err := parser.ExtractTracks("", aidStr, aidStr, cidStr, "", config.UseTVApi, config.UseIntlApi, config.UseAppApi, "")
-	if err != nil {
-		return err
-	}
-
-	// 选择轨道
-	var selectedVideoTrack *Track
-	var selectedAudioTrack *Track
-
-	// 处理视频轨道选择
-	if !config.AudioOnly {
-		selectedVideoTrack, err = selectVideoTrack(tracks, config)
-		if err != nil {
-			return err
-		}
-	}
-
-	// 处理音频轨道选择
-	if !config.VideoOnly {
-		selectedAudioTrack, err = selectAudioTrack(tracks, config)
-		if err != nil {
-			return err
-		}
-	}
-
-	// 下载轨道
-	var videoPath, audioPath string
-	if selectedVideoTrack != nil {
-		fmt.Printf("正在下载视频：%s\n", selectedVideoTrack.Description)
-		videoPath = fmt.Sprintf("%s_video.mp4", video.Part)
-		err = downloadTrack(selectedVideoTrack, videoPath, config)
-		if err != nil {
-			return err
-		}
-	}
-
-	if selectedAudioTrack != nil {
-		fmt.Printf("正在下载音频：%s\n", selectedAudioTrack.Codec)
-		audioPath = fmt.Sprintf("%s_audio.mp4", video.Part)
-		err = downloadTrack(selectedAudioTrack, audioPath, config)
-		if err != nil {
-			return err
-		}
-	}
-
-	// 混流
-	if selectedVideoTrack != nil && selectedAudioTrack != nil {
-		fmt.Printf("正在混流...\n")
-		// 生成输出文件名
-		fileName := video.Part
-		if fileName == "" {
-			// 如果分P标题为空，使用视频标题
-			fileName = video.Title
-		}
-
-		// 清理文件名中的非法字符
-		fileName = strings.ReplaceAll(fileName, "/", "_")
-		fileName = strings.ReplaceAll(fileName, "\\", "_")
-		fileName = strings.ReplaceAll(fileName, ":", "_")
-		fileName = strings.ReplaceAll(fileName, "*", "_")
-		fileName = strings.ReplaceAll(fileName, "?", "_")
-		fileName = strings.ReplaceAll(fileName, "\"", "_")
-		fileName = strings.ReplaceAll(fileName, "<", "_")
-		fileName = strings.ReplaceAll(fileName, ">", "_")
-		fileName = strings.ReplaceAll(fileName, "|", "_")
-
-		// 混流输出文件
-		videoOutputPath := fmt.Sprintf("%s.mp4", fileName)
-		err = muxTracks(selectedVideoTrack, selectedAudioTrack, videoPath, audioPath, videoOutputPath, config)
-		if err != nil {
-			fmt.Printf("混流失败: %v\n", err)
-		} else {
-			fmt.Printf("混流完成: %s\n", videoOutputPath)
-		}
-
-		// 单独保存音频文件
-		if selectedAudioTrack != nil {
-			// 根据音频编码确定文件扩展名
-			audioExt := ".m4a" // 默认扩展名
-			if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "mp3") {
-				audioExt = ".mp3"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "aac") {
-				audioExt = ".aac"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "opus") {
-				audioExt = ".opus"
-			} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "flac") {
-				audioExt = ".flac"
-			}
-
-			audioOutputPath := fmt.Sprintf("%s%s", fileName, audioExt)
-
-			// 复制音频文件到最终位置
-			err = util.CopyFile(audioPath, audioOutputPath)
-			if err != nil {
-				fmt.Printf("保存音频文件失败: %v\n", err)
-			} else {
-				fmt.Printf("音频已保存: %s\n", audioOutputPath)
-			}
-		}
-
-		// 删除临时文件
-		if !config.SimplyMux {
-			if videoPath != "" {
-				if err := os.Remove(videoPath); err != nil {
-					fmt.Printf("删除临时视频文件失败: %v\n", err)
-				}
-			}
-			if audioPath != "" {
-				if err := os.Remove(audioPath); err != nil {
-					fmt.Printf("删除临时音频文件失败: %v\n", err)
-				}
-			}
-		}
-	} else if selectedAudioTrack != nil && selectedVideoTrack == nil {
-		// 只有音频，直接重命名为正确的音频格式
-		fmt.Printf("仅下载音频，重命名文件...\n")
-		// 生成输出文件名
-		fileName := video.Part
-		if fileName == "" {
-			fileName = video.Title
-		}
-
-		// 清理文件名中的非法字符
-		fileName = strings.ReplaceAll(fileName, "/", "_")
-		fileName = strings.ReplaceAll(fileName, "\\", "_")
-		fileName = strings.ReplaceAll(fileName, ":", "_")
-		fileName = strings.ReplaceAll(fileName, "*", "_")
-		fileName = strings.ReplaceAll(fileName, "?", "_")
-		fileName = strings.ReplaceAll(fileName, "\"", "_")
-		fileName = strings.ReplaceAll(fileName, "<", "_")
-		fileName = strings.ReplaceAll(fileName, ">", "_")
-		fileName = strings.ReplaceAll(fileName, "|", "_")
-
-		// 根据音频编码确定文件扩展名
-		audioExt := ".m4a" // 默认扩展名
-		if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "mp3") {
-			audioExt = ".mp3"
-		} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "aac") {
-			audioExt = ".aac"
-		} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "opus") {
-			audioExt = ".opus"
-		} else if strings.Contains(strings.ToLower(selectedAudioTrack.Codec), "flac") {
-			audioExt = ".flac"
-		}
-
-		audioOutputPath := fmt.Sprintf("%s%s", fileName, audioExt)
-		
-		// 重命名文件
-		err := os.Rename(audioPath, audioOutputPath)
-		if err != nil {
-			fmt.Printf("重命名音频文件失败: %v\n", err)
-		} else {
-			fmt.Printf("音频已保存: %s\n", audioOutputPath)
-		}
-	}
-
-	return nil
+	return downloadPage(pageTask{
+		Aid:   video.Aid,
+		Cid:   video.Cid,
+		Bvid:  video.Bvid,
+		Title: video.Title,
+		Part:  video.Part,
+		Index: video.Index,
+	}, config)
 }
 
 // fetchSeasonInfo 获取合集信息
 func fetchSeasonInfo(userID, seasonID string, config *Config) (*SeasonInfo, error) {
-	client := NewHTTPClient()
+	client := httpClient(config)
 
 	// 先尝试网页解析
 	var webURL string
 	if userID != "" {
-		webURL = fmt.Sprintf("https://space.bilibili.com/%s/lists/%s?type=season", userID, seasonID)
+		webURL = client.apiURL("space.bilibili.com", fmt.Sprintf("/%s/lists/%s?type=season", userID, seasonID))
 	} else {
 		// 如果没有用户ID，使用默认值（向后兼容）
-		webURL = fmt.Sprintf("https://space.bilibili.com/89320896/lists/%s?type=season", seasonID)
+		webURL = client.apiURL("space.bilibili.com", fmt.Sprintf("/89320896/lists/%s?type=season", seasonID))
 	}
-	
+
 	webResp, webErr := client.GetWebSource(webURL, config.UserAgent)
 	if webErr == nil {
 		// 如果网页解析成功，尝试解析
@@ -1486,66 +1059,55 @@ func fetchSeasonInfo(userID, seasonID string, config *Config) (*SeasonInfo, erro
 	// 网页解析失败，再尝试API
 	// 先尝试合集API
 	apiEndpoints := []string{
-		fmt.Sprintf("https://api.bilibili.com/x/space/season/video_list?season_id=%s&ps=30&jsonp=jsonp", seasonID),
-		fmt.Sprintf("https://api.bilibili.com/x/season/archives?season_id=%s", seasonID),
-		fmt.Sprintf("https://api.bilibili.com/x/space/arc/search?mid=%s&ps=30&jsonp=jsonp", userID),
+		client.apiURL("api.bilibili.com", fmt.Sprintf("/x/space/season/video_list?season_id=%s&ps=30&jsonp=jsonp", seasonID)),
+		client.apiURL("api.bilibili.com", fmt.Sprintf("/x/season/archives?season_id=%s", seasonID)),
+		client.apiURL("api.bilibili.com", fmt.Sprintf("/x/space/arc/search?mid=%s&ps=30&jsonp=jsonp", userID)),
 	}
-	
+
 	var resp string
 	var err error
-	
-	maxRetries := 1 // 最大重试次数
-	for retry := 0; retry < maxRetries; retry++ {
-		for i, api := range apiEndpoints {
-			// 添加延迟避免频率限制
-			if i > 0 || retry > 0 {
-				time.Sleep(2 * time.Second)
-			}
-			
-			resp, err = client.GetWebSource(api, config.UserAgent)
-			if err == nil {
-				// 检查是否是频率限制错误
-				if strings.Contains(resp, "请求过于频繁") {
-					time.Sleep(5 * time.Second)
-					continue
-				}
-				
-				// 检查响应是否为错误页面
-				if !strings.Contains(resp, "出错啦") && !strings.Contains(resp, "<title>出错") {
-					// 尝试不同的解析方式
-					parsers := []func(string) (*SeasonInfo, error){
-						parseSeasonFromSpaceAPI,
-						parseSeasonFromMedialistAPI,
-						parseSeasonFromSpaceSeasonAPI,
-					}
-
-					for _, parser := range parsers {
-						if seasonInfo, err := parser(resp); err == nil && seasonInfo != nil {
-							return seasonInfo, nil
-						}
-					}
-				}
-			} else {
-				// 如果是频率限制错误，等待更长时间
-				if strings.Contains(err.Error(), "过于频繁") {
-					time.Sleep(10 * time.Second)
-					// 重新开始当前重试循环
-					i--
-					continue
-				}
-			}
+	// apiErr 记录最后一次接口返回的错误码，全部失败时返回给调用方
+	var apiErr error
+
+	// 请求间隔由全局限速器控制
+	for _, api := range apiEndpoints {
+		resp, err = client.GetWebSource(api, config.UserAgent)
+		if errors.Is(err, ErrRiskControl) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		if err := checkAPIResponse(api, resp); err != nil {
+			apiErr = err
+			continue
+		}
+
+		// 检查响应是否为错误页面
+		if strings.Contains(resp, "出错啦") || strings.Contains(resp, "<title>出错") {
+			continue
 		}
-		
-		// 如果已经尝试了所有API但都失败，且还有重试次数，则等待后重试
-		if retry < maxRetries-1 {
-			time.Sleep(15 * time.Second)
+
+		// 尝试不同的解析方式
+		parsers := []func(string) (*SeasonInfo, error){
+			parseSeasonFromSpaceAPI,
+			parseSeasonFromMedialistAPI,
+			parseSeasonFromSpaceSeasonAPI,
+		}
+		for _, parser := range parsers {
+			if seasonInfo, err := parser(resp); err == nil && seasonInfo != nil {
+				return seasonInfo, nil
+			}
 		}
 	}
-	
+
 	// 如果合集API都失败，尝试作为收藏夹处理
-	favAPI := fmt.Sprintf("https://api.bilibili.com/x/v3/fav/resource/list?media_id=%s&ps=30", seasonID)
+	favAPI := client.apiURL("api.bilibili.com", fmt.Sprintf("/x/v3/fav/resource/list?media_id=%s&ps=30", seasonID))
 	resp, err = client.GetWebSource(favAPI, config.UserAgent)
 	if err == nil {
+		if err := checkAPIResponse(favAPI, resp); err != nil {
+			apiErr = err
+		}
 		// 如果收藏夹API成功，使用收藏夹解析器
 		if favInfo, err := parseFavoriteAPI(resp, seasonID); err == nil && favInfo != nil && len(favInfo.Videos) > 0 {
 			// 转换为SeasonInfo格式
@@ -1558,7 +1120,10 @@ func fetchSeasonInfo(userID, seasonID string, config *Config) (*SeasonInfo, erro
 			}, nil
 		}
 	}
-	
+
+	if apiErr != nil {
+		return nil, fmt.Errorf("所有解析方式都失败: %w", apiErr)
+	}
 	return nil, fmt.Errorf("所有解析方式都失败。可能原因：1）合集/收藏夹不存在或已被删除 2）合集/收藏夹为私有 3）用户ID或ID错误")
 }
 
@@ -1586,7 +1151,10 @@ func parseSeasonFromSpaceAPI(resp string) (*SeasonInfo, error) {
 	}
 
 	err := parseJSON(resp, &response)
-	if err != nil || response.Code != 0 {
+	if err == nil && response.Code != 0 {
+		return nil, newAPIError("", response.Code, response.Message)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("API响应失败或解析错误")
 	}
 
@@ -1639,7 +1207,10 @@ func parseSeasonFromMedialistAPI(resp string) (*SeasonInfo, error) {
 	}
 
 	err := parseJSON(resp, &medialistResponse)
-	if err != nil || medialistResponse.Code != 0 || len(medialistResponse.Data.MediaList) == 0 {
+	if err == nil && medialistResponse.Code != 0 {
+		return nil, newAPIError("", medialistResponse.Code, medialistResponse.Message)
+	}
+	if err != nil || len(medialistResponse.Data.MediaList) == 0 {
 		return nil, fmt.Errorf("medialist API解析失败")
 	}
 
@@ -1692,7 +1263,10 @@ func parseSeasonFromSpaceSeasonAPI(resp string) (*SeasonInfo, error) {
 	}
 
 	err := parseJSON(resp, &spaceSeasonResponse)
-	if err != nil || spaceSeasonResponse.Code != 0 || len(spaceSeasonResponse.Data.Archives) == 0 {
+	if err == nil && spaceSeasonResponse.Code != 0 {
+		return nil, newAPIError("", spaceSeasonResponse.Code, spaceSeasonResponse.Message)
+	}
+	if err != nil || len(spaceSeasonResponse.Data.Archives) == 0 {
 		return nil, fmt.Errorf("space season API解析失败")
 	}
 
@@ -1723,17 +1297,16 @@ func parseSeasonFromSpaceSeasonAPI(resp string) (*SeasonInfo, error) {
 
 // parseSeasonFromWeb 从网页解析合集信息
 func parseSeasonFromWeb(html, seasonID string) (*SeasonInfo, error) {
-	
-	
+
 	// 使用正则表达式提取合集信息
 	// 查找初始化数据的JSON（新格式）
 	var jsonRegex *regexp.Regexp
 	var matches [][]string
-	
+
 	// 尝试新格式的初始化数据
 	jsonRegex = regexp.MustCompile(`<script>window\.__INITIAL_STATE__\s*=\s*({.*?});</script>`)
 	matches = jsonRegex.FindAllStringSubmatch(html, -1)
-	
+
 	if len(matches) == 0 || len(matches[0]) < 2 {
 		// 尝试旧格式
 		jsonRegex = regexp.MustCompile(`window\.__INITIAL_STATE__\s*=\s*({.*?});`)
@@ -1742,7 +1315,7 @@ func parseSeasonFromWeb(html, seasonID string) (*SeasonInfo, error) {
 			matches = [][]string{oldMatches}
 		}
 	}
-	
+
 	if len(matches) == 0 || len(matches[0]) < 2 {
 		// 尝试直接从网页中提取基本信息
 		return extractBasicSeasonInfo(html, seasonID)
@@ -1750,84 +1323,55 @@ func parseSeasonFromWeb(html, seasonID string) (*SeasonInfo, error) {
 
 	jsonStr := matches[0][1]
 
-	// 解析JSON
-	var initialState map[string]interface{}
+	// 解析JSON，合集信息可能在space.seasons或seasons下
+	var initialState struct {
+		Space struct {
+			Seasons map[string]*webSeason `json:"seasons"`
+		} `json:"space"`
+		Seasons map[string]*webSeason `json:"seasons"`
+	}
 	err := parseJSON(jsonStr, &initialState)
 	if err != nil {
 		return nil, fmt.Errorf("解析初始化数据失败: %w", err)
 	}
 
-	// 尝试从不同的路径获取合集信息
-	var seasonData map[string]interface{}
-
-	// 尝试路径1
-	if space, ok := initialState["space"].(map[string]interface{}); ok {
-		if seasons, ok := space["seasons"].(map[string]interface{}); ok {
-			if currentSeason, ok := seasons[seasonID].(map[string]interface{}); ok {
-				seasonData = currentSeason
-			}
-		}
-	}
-
-	// 尝试路径2
+	seasonData := initialState.Space.Seasons[seasonID]
 	if seasonData == nil {
-		if seasons, ok := initialState["seasons"].(map[string]interface{}); ok {
-			if currentSeason, ok := seasons[seasonID].(map[string]interface{}); ok {
-				seasonData = currentSeason
-			}
-		}
+		seasonData = initialState.Seasons[seasonID]
 	}
-
 	if seasonData == nil {
 		// 尝试从网页中提取基本信息
 		return extractBasicSeasonInfo(html, seasonID)
 	}
 
-	// 提取基本信息
-	seasonName := getStringFromMap(seasonData, "name")
-	description := getStringFromMap(seasonData, "description")
-
-	// 提取视频列表
-	var videos []SeasonVideo
-	if archives, ok := seasonData["archives"].([]interface{}); ok {
-		for _, archive := range archives {
-			if archiveMap, ok := archive.(map[string]interface{}); ok {
-				video := SeasonVideo{
-					Aid:      getInt64FromMap(archiveMap, "aid"),
-					Bvid:     getStringFromMap(archiveMap, "bvid"),
-					Cid:      getInt64FromMap(archiveMap, "cid"),
-					Title:    getStringFromMap(archiveMap, "title"),
-					Duration: getIntFromMap(archiveMap, "duration"),
-					Cover:    getStringFromMap(archiveMap, "cover"),
-					Index:    getIntFromMap(archiveMap, "index"),
-					Part:     getStringFromMap(archiveMap, "part"),
-				}
-				videos = append(videos, video)
-			}
-		}
-	}
-
 	return &SeasonInfo{
 		SeasonID:    seasonID,
-		SeasonName:  seasonName,
-		Description: description,
-		TotalCount:  len(videos),
-		Videos:      videos,
+		SeasonName:  seasonData.Name,
+		Description: seasonData.Description,
+		TotalCount:  len(seasonData.Archives),
+		Videos:      seasonData.Archives,
 	}, nil
 }
 
+// webSeason 网页初始化数据中的合集
+type webSeason struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Archives    []SeasonVideo `json:"archives"`
+}
+
 // extractBasicSeasonInfo 从网页中提取基本合集信息（备用方案）
 func extractBasicSeasonInfo(html, seasonID string) (*SeasonInfo, error) {
 	// 检查是否是验证码页面
-	if strings.Contains(html, "验证码_哔哩哔哩") || strings.Contains(html, "risk-captcha") {
-		return nil, fmt.Errorf("遇到验证码页面，无法访问合集内容")
+	if isCaptchaPage(html) {
+		return nil, &RiskControlError{Reason: "遇到验证码页面，无法访问合集内容"}
 	}
-	
+
 	// 检查是否是错误页面
 	if strings.Contains(html, "出错啦") || strings.Contains(html, "<title>出错") {
 		return nil, fmt.Errorf("页面不存在或访问出错")
 	}
-	
+
 	// 提取合集标题
 	titleRegex := regexp.MustCompile(`<h1[^>]*class="[^"]*title[^"]*"[^>]*>([^<]+)</h1>`)
 	titleMatches := titleRegex.FindStringSubmatch(html)
@@ -1835,7 +1379,7 @@ func extractBasicSeasonInfo(html, seasonID string) (*SeasonInfo, error) {
 	if len(titleMatches) > 1 {
 		seasonName = strings.TrimSpace(titleMatches[1])
 	}
-	
+
 	// 如果没有找到标题，尝试其他方式
 	if seasonName == "未知合集" {
 		titleRegex2 := regexp.MustCompile(`"title":"([^"]+)"`)
@@ -1844,16 +1388,16 @@ func extractBasicSeasonInfo(html, seasonID string) (*SeasonInfo, error) {
 			seasonName = strings.TrimSpace(titleMatches2[1])
 		}
 	}
-	
+
 	// 如果仍然是验证码页面标题，直接返回错误
 	if seasonName == "验证码_哔哩哔哩" {
-		return nil, fmt.Errorf("遇到验证码页面，无法访问合集内容")
+		return nil, &RiskControlError{Reason: "遇到验证码页面，无法访问合集内容"}
 	}
-	
+
 	// 提取视频列表（新格式尝试）
 	videoRegex := regexp.MustCompile(`<a[^>]*href="([^"]*video/([^"]*))"[^>]*>.*?<span[^>]*class="[^"]*title[^"]*"[^>]*>([^<]+)</span>`)
 	videoMatches := videoRegex.FindAllStringSubmatch(html, -1)
-	
+
 	// 如果新格式没有找到，尝试更简单的模式
 	if len(videoMatches) == 0 {
 		videoRegex = regexp.MustCompile(`<a[^>]*href="/video/([^"]+)"[^>]*>([^<]+)</a>`)
@@ -1893,49 +1437,10 @@ func extractBasicSeasonInfo(html, seasonID string) (*SeasonInfo, error) {
 	}, nil
 }
 
-// getStringFromMap 从map中安全获取字符串值
-func getStringFromMap(m map[string]interface{}, key string) string {
-	if val, ok := m[key]; ok {
-		if str, ok := val.(string); ok {
-			return str
-		}
-	}
-	return ""
-}
-
-// getIntFromMap 从map中安全获取int值
-func getIntFromMap(m map[string]interface{}, key string) int {
-	if val, ok := m[key]; ok {
-		if f, ok := val.(float64); ok {
-			return int(f)
-		}
-		if i, ok := val.(int); ok {
-			return i
-		}
-	}
-	return 0
-}
-
-// getInt64FromMap 从map中安全获取int64值
-func getInt64FromMap(m map[string]interface{}, key string) int64 {
-	if val, ok := m[key]; ok {
-		if f, ok := val.(float64); ok {
-			return int64(f)
-		}
-		if i, ok := val.(int); ok {
-			return int64(i)
-		}
-		if i64, ok := val.(int64); ok {
-			return i64
-		}
-	}
-	return 0
-}
-
 // parseFavoriteAPI 解析收藏夹API响应
 func parseFavoriteAPI(resp string, favID string) (*FavoriteInfo, error) {
 	var response struct {
-		Code    int `json:"code"`
+		Code    int    `json:"code"`
 		Message string `json:"message"`
 		Data    *struct {
 			ID          string `json:"id"`
@@ -1957,9 +1462,12 @@ func parseFavoriteAPI(resp string, favID string) (*FavoriteInfo, error) {
 	}
 
 	err := parseJSON(resp, &response)
-	if err != nil || response.Code != 0 {
+	if err != nil {
 		return nil, fmt.Errorf("收藏夹API解析失败")
 	}
+	if response.Code != 0 {
+		return nil, newAPIError("", response.Code, response.Message)
+	}
 
 	if response.Data == nil || len(response.Data.Medias) == 0 {
 		return nil, fmt.Errorf("收藏夹为空或不存在")
@@ -2016,10 +1524,10 @@ func min(a, b int) int {
 
 // fetchMediaListInfo 获取媒体列表信息
 func fetchMediaListInfo(medialistID string, config *Config) (*MediaListInfo, error) {
-	client := NewHTTPClient()
+	client := httpClient(config)
 
 	// 获取媒体列表信息API
-	api := fmt.Sprintf("https://api.bilibili.com/x/v2/medialist/info?ml_id=%s", medialistID)
+	api := client.apiURL("api.bilibili.com", fmt.Sprintf("/x/v2/medialist/info?ml_id=%s", medialistID))
 
 	resp, err := client.GetWebSource(api, config.UserAgent)
 	if err != nil {
@@ -2054,7 +1562,7 @@ func fetchMediaListInfo(medialistID string, config *Config) (*MediaListInfo, err
 	}
 
 	if response.Code != 0 {
-		return nil, fmt.Errorf("API错误: %s", response.Message)
+		return nil, newAPIError(api, response.Code, response.Message)
 	}
 
 	// 转换为MediaListInfo结构
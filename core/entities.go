@@ -32,6 +32,7 @@ type ViewPoint struct {
 
 // VInfo 视频信息
 type VInfo struct {
+	Bvid        string `json:"bvid"`
 	Title       string `json:"title"`
 	Desc        string `json:"desc"`
 	Pic         string `json:"pic"`
@@ -41,6 +42,7 @@ type VInfo struct {
 	IsBangumi   bool   `json:"isBangumi"`
 	IsCheese    bool   `json:"isCheese"`
 	IsSteinGate bool   `json:"isSteinGate"`
+	Rights      Rights `json:"rights"`
 	SeasonID    string `json:"seasonId,omitempty"`
 	SeasonName  string `json:"seasonName,omitempty"`
 	Stat        struct {
@@ -62,21 +64,52 @@ type Owner struct {
 
 // Track 音视频轨道
 type Track struct {
-	ID          int      `json:"id"`
-	Codecid     int      `json:"codecid"`
-	Quality     int      `json:"quality"`
-	Description string   `json:"description"`
-	URL         string   `json:"url"`
-	MD5         string   `json:"md5"`
-	Size        int64    `json:"size"`
-	Bandwidth   int      `json:"bandwidth"`
-	FPS         int      `json:"fps"`
-	Width       int      `json:"width"`
-	Height      int      `json:"height"`
-	Format      string   `json:"format"`
-	Codec       string   `json:"codec"`
-	FrameType   string   `json:"frameType"`            // "video" or "audio"
-	BackupURLs  []string `json:"backupUrls,omitempty"` // 备份URL
+	ID          int       `json:"id"`
+	Codecid     int       `json:"codecid"`
+	Quality     int       `json:"quality"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	MD5         string    `json:"md5"`
+	Size        int64     `json:"size"`
+	Bandwidth   int       `json:"bandwidth"`
+	FPS         int       `json:"fps"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Format      string    `json:"format"`
+	Codec       string    `json:"codec"`
+	Codecs      string    `json:"codecs,omitempty"`     // 原始codecs字符串，如 hev1.1.6.L150.90
+	FrameType   string    `json:"frameType"`            // "video" or "audio"
+	BackupURLs  []string  `json:"backupUrls,omitempty"` // 备份URL
+	Language    string    `json:"language,omitempty"`   // 音轨语言代码，如 en-US
+	Segments    []Segment `json:"segments,omitempty"`   // 分段文件（FLV/durl），按顺序拼接
+
+	// DASH流信息，用于生成MPD/HLS清单和按时间范围下载
+	MimeType     string       `json:"mimeType,omitempty"`
+	Sar          string       `json:"sar,omitempty"`
+	StartWithSap int          `json:"startWithSap,omitempty"`
+	FrameRate    string       `json:"frameRate,omitempty"` // 原始帧率字符串，如 29.970
+	SegmentBase  *SegmentBase `json:"segmentBase,omitempty"`
+}
+
+// SegmentBase DASH流中初始化段和索引(sidx)在文件中的字节范围
+type SegmentBase struct {
+	Initialization string `json:"initialization"` // 如 "0-927"
+	IndexRange     string `json:"indexRange"`     // 如 "928-1395"
+}
+
+// Segment 分段文件信息
+type Segment struct {
+	Index      int      `json:"order"`
+	URL        string   `json:"url"`
+	BackupURLs []string `json:"backupUrls,omitempty"`
+	Size       int64    `json:"size"`
+	Length     int64    `json:"length"` // 时长(毫秒)
+}
+
+// AudioLanguage 可选的配音语言
+type AudioLanguage struct {
+	Lang  string `json:"lang"`  // 语言代码，如 en-US
+	Title string `json:"title"` // 显示名称，如 English
 }
 
 // ParsedResult 解析结果
@@ -197,10 +230,10 @@ type MediaVideo struct {
 
 // FavoriteInfo 收藏夹信息
 type FavoriteInfo struct {
-	ID          string         `json:"id"`
-	Title       string         `json:"title"`
-	Description string         `json:"description"`
-	TotalCount  int            `json:"total_count"`
+	ID          string          `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	TotalCount  int             `json:"total_count"`
 	Videos      []FavoriteVideo `json:"videos"`
 }
 
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// volatileParams 每次请求都会变化的参数，不参与回放匹配
+var volatileParams = []string{"wts", "w_rid", "ts", "sign"}
+
+// redactedParams 录制时需要隐藏的查询参数
+var redactedParams = []string{"access_key", "csrf"}
+
+// redactedHeaders 录制时需要隐藏的请求头和响应头
+var redactedHeaders = []string{"Cookie", "Set-Cookie", "Authorization"}
+
+// redactedValue 隐藏后的值
+const redactedValue = "REDACTED"
+
+// fixtureNameFilter 文件名中不允许的字符
+var fixtureNameFilter = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// httpFixture 录制的一组请求和响应
+type httpFixture struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	RequestHeader http.Header `json:"requestHeader,omitempty"`
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header,omitempty"`
+	Body          string      `json:"body"`
+}
+
+// recordTransport 转发请求并将API响应保存为fixture文件
+type recordTransport struct {
+	dir  string
+	next http.RoundTripper
+	mu   sync.Mutex
+}
+
+// NewRecordTransport 创建录制传输，API请求的响应保存到dir，视频流等二进制响应不录制
+// Cookie、access_key等敏感信息会被隐藏
+func NewRecordTransport(dir string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordTransport{dir: dir, next: next}
+}
+
+// RoundTrip 发送请求并录制响应
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Header.Get("Range") != "" || !isTextResponse(resp) {
+		return resp, err
+	}
+
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("录制响应失败: %w", err)
+	}
+	// 保存和返回解压后的内容，fixture便于阅读
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := httpFixture{
+		Method:        req.Method,
+		URL:           normalizeFixtureURL(req.URL, false),
+		RequestHeader: redactHeader(req.Header),
+		Status:        resp.StatusCode,
+		Header:        redactHeader(resp.Header),
+		Body:          string(body),
+	}
+	if err := t.save(req, &fixture); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// save 写入fixture文件
+func (t *recordTransport) save(req *http.Request, fixture *httpFixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("录制响应失败: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(t.dir, fixtureName(req)), data, 0644); err != nil {
+		return fmt.Errorf("写入录制文件失败: %w", err)
+	}
+	return nil
+}
+
+// replayTransport 从fixture文件返回响应，不访问网络
+type replayTransport struct {
+	dir string
+}
+
+// NewReplayTransport 创建回放传输，请求按方法和地址匹配dir中的fixture文件
+// wts、w_rid等随时间变化的参数不参与匹配，找不到fixture时返回错误
+func NewReplayTransport(dir string) http.RoundTripper {
+	return &replayTransport{dir: dir}
+}
+
+// RoundTrip 读取对应的fixture作为响应
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := fixtureName(req)
+	data, err := os.ReadFile(filepath.Join(t.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("回放数据中没有该请求 %s %s (%s): %w", req.Method, normalizeFixtureURL(req.URL, false), name, err)
+	}
+
+	var fixture httpFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("解析回放文件%s失败: %w", name, err)
+	}
+
+	header := fixture.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+		StatusCode:    fixture.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(fixture.Body)),
+		ContentLength: int64(len(fixture.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fixtureName 根据请求生成fixture文件名：主机和路径便于识别，哈希区分不同参数
+func fixtureName(req *http.Request) string {
+	key := req.Method + " " + normalizeFixtureURL(req.URL, true)
+	sum := sha1.Sum([]byte(key))
+
+	prefix := fixtureNameFilter.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	prefix = strings.Trim(prefix, "_")
+	if len(prefix) > 80 {
+		prefix = prefix[:80]
+	}
+	return fmt.Sprintf("%s_%s.json", prefix, hex.EncodeToString(sum[:])[:12])
+}
+
+// normalizeFixtureURL 隐藏敏感参数并按参数名排序，matching为true时去掉随时间变化的参数
+func normalizeFixtureURL(u *url.URL, matching bool) string {
+	query := u.Query()
+	for _, key := range redactedParams {
+		if query.Has(key) {
+			query.Set(key, redactedValue)
+		}
+	}
+	if matching {
+		for _, key := range volatileParams {
+			query.Del(key)
+		}
+	}
+
+	normalized := *u
+	normalized.RawQuery = query.Encode()
+	normalized.Fragment = ""
+	return normalized.String()
+}
+
+// redactHeader 复制header并隐藏敏感字段
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, key := range redactedHeaders {
+		if redacted.Get(key) != "" {
+			redacted.Set(key, redactedValue)
+		}
+	}
+	return redacted
+}
+
+// isTextResponse 判断响应是否为JSON、HTML等文本内容
+func isTextResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/javascript"
+}
+
+// readResponseBody 读取并关闭响应体，gzip压缩的内容会被解压
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,77 @@
+package core
+
+import "strings"
+
+// HDR相关的画质ID
+const (
+	qualityHDR         = 125 // HDR 真彩
+	qualityDolbyVision = 126 // 杜比视界
+)
+
+// isDolbyVision 判断是否为杜比视界视频
+func isDolbyVision(track *Track) bool {
+	if track == nil {
+		return false
+	}
+	if track.ID == qualityDolbyVision {
+		return true
+	}
+	codecs := strings.ToLower(track.Codecs)
+	for _, prefix := range []string{"dvh1", "dvhe", "dav1", "dva1", "dvav"} {
+		if strings.HasPrefix(codecs, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHDRVideo 判断是否为HDR10视频（不含杜比视界）
+func isHDRVideo(track *Track) bool {
+	return track != nil && track.ID == qualityHDR && !isDolbyVision(track)
+}
+
+// dynamicRangeLabel 返回视频的动态范围标记，用于文件名和元数据，SDR返回空字符串
+func dynamicRangeLabel(track *Track) string {
+	switch {
+	case isDolbyVision(track):
+		return "DV"
+	case isHDRVideo(track):
+		return "HDR"
+	default:
+		return ""
+	}
+}
+
+// hdrMuxArgs 返回保留HDR/杜比视界信息所需的FFmpeg参数
+//
+// FFmpeg的MP4封装默认不写入dvcC/dvvC配置盒以及mdcv/clli母版显示信息，
+// 需要 -strict unofficial 才会保留；同时设置正确的sample entry标签，
+// 否则部分播放器会把杜比视界当作普通HEVC播放。
+func hdrMuxArgs(track *Track, container string) []string {
+	label := dynamicRangeLabel(track)
+	if label == "" {
+		return nil
+	}
+
+	args := []string{"-strict", "unofficial"}
+	if container == ".mp4" || container == ".mov" {
+		switch {
+		case label == "DV" && track.Codec == "HEVC":
+			args = append(args, "-tag:v", "dvh1")
+		case track.Codec == "HEVC":
+			args = append(args, "-tag:v", "hvc1")
+		}
+	}
+	return args
+}
+
+// hdrMetadataArgs 返回标记HDR视频轨道标题的FFmpeg参数
+func hdrMetadataArgs(track *Track) []string {
+	switch dynamicRangeLabel(track) {
+	case "DV":
+		return []string{"-metadata:s:v:0", "title=Dolby Vision"}
+	case "HDR":
+		return []string{"-metadata:s:v:0", "title=HDR10"}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,37 +19,177 @@ import (
 	"github.com/tekintian/go-bbdown/util"
 )
 
+// HTTP超时默认值
+const (
+	defaultConnectTimeout        = 10 * time.Second
+	defaultResponseHeaderTimeout = 30 * time.Second
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultAPITimeout            = time.Minute
+)
+
 // HTTPClient HTTP客户端
+// 下载请求只受连接和响应头超时限制，不限制总传输时间；API请求额外受APITimeout限制
 type HTTPClient struct {
-	Client *http.Client
+	Client     *http.Client
+	APITimeout time.Duration     // 单次API请求的总超时，0表示不限制
+	BaseURLs   map[string]string // 按主机名替换接口地址，如 "api.bilibili.com" -> "http://127.0.0.1:8080"
+	Cookie     string            // 登录Cookie，只发送给B站的接口和网页
+	wbiCache   *wbiKeyCache      // WBI密钥缓存，为nil时使用全局共享缓存
+	apiLimiter *rateLimiter      // 信息和播放地址接口共用的限速器，为nil时不限速
+}
+
+// HTTPOptions HTTP客户端选项，零值字段使用默认值
+type HTTPOptions struct {
+	Insecure              bool          // 跳过TLS证书校验
+	ConnectTimeout        time.Duration // 建立TCP连接和TLS握手的超时
+	ResponseHeaderTimeout time.Duration // 发出请求后等待响应头的超时
+	IdleConnTimeout       time.Duration // 空闲连接保留时间
+	APITimeout            time.Duration // API请求的总超时
+	MaxConnsPerHost       int           // 每个主机的最大连接数，0表示不限制
+
+	// Transport 自定义底层传输，设置后忽略上面的连接相关选项，用于测试和回放
+	Transport http.RoundTripper
+	// BaseURLs 按主机名替换接口地址，用于指向本地模拟服务器
+	BaseURLs map[string]string
+	// Cookie 登录Cookie，如 "SESSDATA=xxx"
+	Cookie string
+	// RecordDir 录制API响应的目录，ReplayDir 回放API响应的目录，同时设置时只回放
+	RecordDir string
+	ReplayDir string
 }
 
-// NewHTTPClient 创建新的HTTP客户端
+// NewHTTPClient 使用默认选项创建HTTP客户端
 func NewHTTPClient() *HTTPClient {
+	return NewHTTPClientWithOptions(HTTPOptions{})
+}
+
+// NewHTTPClientWithOptions 按选项创建HTTP客户端
+func NewHTTPClientWithOptions(opts HTTPOptions) *HTTPClient {
+	connectTimeout := durationOrDefault(opts.ConnectTimeout, defaultConnectTimeout)
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: durationOrDefault(opts.ResponseHeaderTimeout, defaultResponseHeaderTimeout),
+		IdleConnTimeout:       durationOrDefault(opts.IdleConnTimeout, defaultIdleConnTimeout),
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+	}
+	if opts.MaxConnsPerHost > transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = opts.MaxConnsPerHost
+	}
+	if opts.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if opts.Transport != nil {
+		roundTripper = opts.Transport
+	}
+	if opts.ReplayDir != "" {
+		roundTripper = NewReplayTransport(opts.ReplayDir)
+	} else if opts.RecordDir != "" {
+		roundTripper = NewRecordTransport(opts.RecordDir, roundTripper)
 	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   2 * time.Minute,
+	client := &HTTPClient{
+		Client:     &http.Client{Transport: roundTripper},
+		APITimeout: durationOrDefault(opts.APITimeout, defaultAPITimeout),
+		BaseURLs:   opts.BaseURLs,
+		Cookie:     opts.Cookie,
+		apiLimiter: &rateLimiter{interval: time.Second / defaultAPIRateLimit},
 	}
+	// 接口指向其他服务器时密钥可能不同，使用独立的缓存
+	if len(opts.BaseURLs) > 0 {
+		client.wbiCache = &wbiKeyCache{}
+	}
+	return client
+}
 
-	return &HTTPClient{Client: client}
+// apiURL 拼接接口地址，host为主机名或带协议的基础地址，path以/开头并可带查询参数
+// 未指定协议时使用https；返回的是逻辑地址，BaseURLs的替换在发送请求时进行
+func (h *HTTPClient) apiURL(host, path string) string {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return strings.TrimSuffix(host, "/") + path
 }
 
-// GetWebSource 获取网页内容
+// resolveURL 按BaseURLs将逻辑地址替换为实际请求的地址
+func (h *HTTPClient) resolveURL(urlStr string) string {
+	if len(h.BaseURLs) == 0 {
+		return urlStr
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return urlStr
+	}
+	base, ok := h.BaseURLs[u.Host]
+	if !ok {
+		return urlStr
+	}
+	return strings.TrimSuffix(base, "/") + u.RequestURI()
+}
+
+// requestHost 返回地址的主机名，解析失败时返回空字符串
+func requestHost(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// setCookie 请求B站的地址时带上登录Cookie，其他主机不发送
+func (h *HTTPClient) setCookie(req *http.Request, urlStr string) {
+	if h.Cookie == "" {
+		return
+	}
+	host := requestHost(urlStr)
+	for _, domain := range []string{"bilibili.com", "bilibili.tv", "biliintl.com"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			req.Header.Set("Cookie", h.Cookie)
+			return
+		}
+	}
+}
+
+// durationOrDefault 未设置时返回默认值
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// apiContext 返回带API超时的上下文
+func (h *HTTPClient) apiContext() (context.Context, context.CancelFunc) {
+	if h.APITimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), h.APITimeout)
+}
+
+// GetWebSource 获取网页内容，遇到风控时自动等待重试
 func (h *HTTPClient) GetWebSource(urlStr, userAgent string) (string, error) {
+	return withRiskControlBackoff(func() (string, error) {
+		return h.getWebSource(urlStr, userAgent)
+	})
+}
+
+// getWebSource 发送一次GET请求并检查响应是否被风控拦截
+func (h *HTTPClient) getWebSource(urlStr, userAgent string) (string, error) {
 	if userAgent == "" {
 		userAgent = getRandomUserAgent()
 	}
 
-	req, err := http.NewRequest("GET", urlStr, nil)
+	req, err := http.NewRequest("GET", h.resolveURL(urlStr), nil)
 	if err != nil {
 		return "", fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -68,29 +209,31 @@ func (h *HTTPClient) GetWebSource(urlStr, userAgent string) (string, error) {
 	req.Header.Set("Sec-Fetch-User", "?1")
 	req.Header.Set("Upgrade-Insecure-Requests", "1")
 
-	if strings.Contains(urlStr, "api.bilibili.com") {
+	// 按逻辑主机名决定请求头，地址被BaseURLs替换后仍然带上Referer
+	switch requestHost(urlStr) {
+	case "api.bilibili.com", "space.bilibili.com":
 		req.Header.Set("Referer", "https://www.bilibili.com/")
-	}
-	if strings.Contains(urlStr, "api.bilibili.tv") {
+	case "api.bilibili.tv":
 		req.Header.Set("sec-ch-ua", `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`)
 	}
-	if strings.Contains(urlStr, "space.bilibili.com") {
-		req.Header.Set("Referer", "https://www.bilibili.com/")
-	}
+	h.setCookie(req, urlStr)
 
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
 
-	resp, err := h.Client.Do(req)
+	h.apiLimiter.Wait()
+	ctx, cancel := h.apiContext()
+	defer cancel()
+	resp, err := h.Client.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		// 处理频率限制
-		if resp.StatusCode == 429 {
-			return "", fmt.Errorf("请求过于频繁，请稍后再试")
+		// 处理风控和频率限制
+		if err := checkRiskStatus(resp.StatusCode); err != nil {
+			return "", err
 		}
 		return "", fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
 	}
@@ -111,6 +254,10 @@ func (h *HTTPClient) GetWebSource(urlStr, userAgent string) (string, error) {
 		return "", fmt.Errorf("读取响应失败: %w", err)
 	}
 
+	if err := checkRiskBody(string(body)); err != nil {
+		return "", err
+	}
+
 	return string(body), nil
 }
 
@@ -120,7 +267,7 @@ func (h *HTTPClient) GetWebLocation(urlStr, userAgent string) (string, error) {
 		userAgent = getRandomUserAgent()
 	}
 
-	req, err := http.NewRequest("HEAD", urlStr, nil)
+	req, err := http.NewRequest("HEAD", h.resolveURL(urlStr), nil)
 	if err != nil {
 		return "", fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -129,17 +276,21 @@ func (h *HTTPClient) GetWebLocation(urlStr, userAgent string) (string, error) {
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
+	h.setCookie(req, urlStr)
 
-	resp, err := h.Client.Do(req)
+	h.apiLimiter.Wait()
+	ctx, cancel := h.apiContext()
+	defer cancel()
+	resp, err := h.Client.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		// 处理频率限制
-		if resp.StatusCode == 429 {
-			return "", fmt.Errorf("请求过于频繁，请稍后再试")
+		// 处理风控和频率限制
+		if err := checkRiskStatus(resp.StatusCode); err != nil {
+			return "", err
 		}
 		return "", fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
 	}
@@ -148,9 +299,16 @@ func (h *HTTPClient) GetWebLocation(urlStr, userAgent string) (string, error) {
 	return finalURL, nil
 }
 
-// PostRequest 发送POST请求
+// PostRequest 发送POST请求，遇到风控时自动等待重试
 func (h *HTTPClient) PostRequest(urlStr string, data []byte, headers map[string]string) (string, error) {
-	req, err := http.NewRequest("POST", urlStr, bytes.NewBuffer(data))
+	return withRiskControlBackoff(func() (string, error) {
+		return h.postRequest(urlStr, data, headers)
+	})
+}
+
+// postRequest 发送一次POST请求
+func (h *HTTPClient) postRequest(urlStr string, data []byte, headers map[string]string) (string, error) {
+	req, err := http.NewRequest("POST", h.resolveURL(urlStr), bytes.NewBuffer(data))
 	if err != nil {
 		return "", fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -168,16 +326,19 @@ func (h *HTTPClient) PostRequest(urlStr string, data []byte, headers map[string]
 		req.Header.Set("grpc-encoding", "gzip")
 	}
 
-	resp, err := h.Client.Do(req)
+	h.apiLimiter.Wait()
+	ctx, cancel := h.apiContext()
+	defer cancel()
+	resp, err := h.Client.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		// 处理频率限制
-		if resp.StatusCode == 429 {
-			return "", fmt.Errorf("请求过于频繁，请稍后再试")
+		// 处理风控和频率限制
+		if err := checkRiskStatus(resp.StatusCode); err != nil {
+			return "", err
 		}
 		return "", fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
 	}
@@ -300,9 +461,9 @@ func buildQueryStringHTTP(params map[string]string) string {
 	return values.Encode()
 }
 
-// GetWBIKey 获取WBI密钥
+// GetWBIKey 从nav接口获取WBI混合密钥，不使用缓存
 func GetWBIKey(client *HTTPClient) (string, error) {
-	api := "https://api.bilibili.com/x/web-interface/nav"
+	api := client.apiURL("api.bilibili.com", "/x/web-interface/nav")
 
 	resp, err := client.GetWebSource(api, "")
 	if err != nil {
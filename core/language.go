@@ -0,0 +1,154 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// iso6392Codes 常见语言代码到ISO 639-2的映射，用于写入容器的语言标签
+var iso6392Codes = map[string]string{
+	"zh": "chi",
+	"en": "eng",
+	"ja": "jpn",
+	"ko": "kor",
+	"es": "spa",
+	"fr": "fre",
+	"de": "ger",
+	"ru": "rus",
+	"pt": "por",
+	"it": "ita",
+	"th": "tha",
+	"vi": "vie",
+	"id": "ind",
+	"ms": "may",
+	"ar": "ara",
+}
+
+// parseLanguageList 解析逗号分隔的语言列表
+func parseLanguageList(languages string) []string {
+	var result []string
+	for _, lang := range strings.Split(languages, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang != "" {
+			result = append(result, lang)
+		}
+	}
+	return result
+}
+
+// matchLanguage 在可选配音中查找语言，支持语言代码、语言前缀（如en匹配en-US）和显示名称
+func matchLanguage(languages []AudioLanguage, want string) (AudioLanguage, bool) {
+	for _, lang := range languages {
+		if strings.EqualFold(lang.Lang, want) || strings.EqualFold(lang.Title, want) {
+			return lang, true
+		}
+	}
+	for _, lang := range languages {
+		if strings.EqualFold(languagePrefix(lang.Lang), languagePrefix(want)) {
+			return lang, true
+		}
+	}
+	return AudioLanguage{}, false
+}
+
+// languagePrefix 返回语言代码的主语言部分，如 zh-Hans 返回 zh
+func languagePrefix(lang string) string {
+	if idx := strings.IndexAny(lang, "-_"); idx > 0 {
+		return lang[:idx]
+	}
+	return lang
+}
+
+// iso6392 将语言代码转换为ISO 639-2三字母代码，无法识别时返回und
+func iso6392(lang string) string {
+	prefix := strings.ToLower(languagePrefix(lang))
+	if code, ok := iso6392Codes[prefix]; ok {
+		return code
+	}
+	if len(prefix) == 3 {
+		return prefix
+	}
+	return "und"
+}
+
+// selectAudioTracks 选择要下载的音频轨道
+// 未指定语言或视频不支持多语言时只选择默认音轨；指定 all 时选择全部配音
+func selectAudioTracks(parser *Parser, tracks []*Track, aid, cid string, config *Config) ([]*Track, error) {
+	wanted := parseLanguageList(config.Language)
+	if len(wanted) == 0 || len(parser.Languages) == 0 {
+		if len(wanted) > 0 {
+			fmt.Printf("该视频没有多语言配音，使用默认音轨\n")
+		}
+		track, err := selectAudioTrack(tracks, config)
+		if err != nil {
+			return nil, err
+		}
+		return []*Track{track}, nil
+	}
+
+	var languages []AudioLanguage
+	if len(wanted) == 1 && strings.EqualFold(wanted[0], "all") {
+		languages = parser.Languages
+	} else {
+		for _, want := range wanted {
+			lang, ok := matchLanguage(parser.Languages, want)
+			if !ok {
+				fmt.Printf("未找到配音语言：%s，可选语言：%s\n", want, formatLanguages(parser.Languages))
+				continue
+			}
+			languages = append(languages, lang)
+		}
+	}
+
+	var selected []*Track
+	seen := make(map[string]bool)
+	for _, lang := range languages {
+		if seen[lang.Lang] {
+			continue
+		}
+		seen[lang.Lang] = true
+
+		langTracks, err := parser.ExtractLanguageTracks(aid, aid, cid, "", lang.Lang)
+		if err != nil {
+			return nil, fmt.Errorf("获取%s配音失败: %w", lang.Lang, err)
+		}
+		track, err := selectAudioTrack(langTracks, config)
+		if err != nil {
+			return nil, fmt.Errorf("选择%s配音失败: %w", lang.Lang, err)
+		}
+		track.Language = lang.Lang
+		selected = append(selected, track)
+	}
+
+	if len(selected) == 0 {
+		fmt.Printf("没有匹配的配音语言，使用默认音轨\n")
+		track, err := selectAudioTrack(tracks, config)
+		if err != nil {
+			return nil, err
+		}
+		return []*Track{track}, nil
+	}
+
+	return selected, nil
+}
+
+// formatLanguages 格式化可选语言列表
+func formatLanguages(languages []AudioLanguage) string {
+	names := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		if lang.Title != "" {
+			names = append(names, fmt.Sprintf("%s(%s)", lang.Lang, lang.Title))
+		} else {
+			names = append(names, lang.Lang)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// describeAudio 返回音频轨道的描述，包含语言
+func describeAudio(track *Track) string {
+	if track.Language != "" {
+		return fmt.Sprintf("%s [%s]", track.Codec, track.Language)
+	}
+	return track.Codec
+}
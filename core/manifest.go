@@ -0,0 +1,392 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mpdManifest DASH MPD清单 (ISO/IEC 23009-1, on-demand profile)
+type mpdManifest struct {
+	XMLName                   xml.Name  `xml:"MPD"`
+	Xmlns                     string    `xml:"xmlns,attr"`
+	Profiles                  string    `xml:"profiles,attr"`
+	Type                      string    `xml:"type,attr"`
+	MediaPresentationDuration string    `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string    `xml:"minBufferTime,attr"`
+	Period                    mpdPeriod `xml:"Period"`
+}
+
+// mpdPeriod MPD中的时间段，B站视频只有一个
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	Start          string             `xml:"start,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+// mpdAdaptationSet 同一类可相互切换的流
+type mpdAdaptationSet struct {
+	ID                      int                 `xml:"id,attr"`
+	ContentType             string              `xml:"contentType,attr"`
+	MimeType                string              `xml:"mimeType,attr"`
+	Lang                    string              `xml:"lang,attr,omitempty"`
+	SubsegmentAlignment     bool                `xml:"subsegmentAlignment,attr"`
+	SubsegmentStartsWithSAP int                 `xml:"subsegmentStartsWithSAP,attr"`
+	Representations         []mpdRepresentation `xml:"Representation"`
+}
+
+// mpdRepresentation 一路具体的音视频流
+type mpdRepresentation struct {
+	ID          string         `xml:"id,attr"`
+	Bandwidth   int            `xml:"bandwidth,attr"`
+	Codecs      string         `xml:"codecs,attr"`
+	Width       int            `xml:"width,attr,omitempty"`
+	Height      int            `xml:"height,attr,omitempty"`
+	FrameRate   string         `xml:"frameRate,attr,omitempty"`
+	Sar         string         `xml:"sar,attr,omitempty"`
+	BaseURL     string         `xml:"BaseURL"`
+	SegmentBase mpdSegmentBase `xml:"SegmentBase"`
+}
+
+// mpdSegmentBase 单文件流的初始化段和sidx索引位置
+type mpdSegmentBase struct {
+	IndexRange      string `xml:"indexRange,attr"`
+	IndexRangeExact bool   `xml:"indexRangeExact,attr"`
+	Initialization  struct {
+		Range string `xml:"range,attr"`
+	} `xml:"Initialization"`
+}
+
+// exportManifests 解析分P的DASH轨道并生成MPD和HLS清单，不下载音视频
+// 清单直接引用B站CDN地址，地址有时效，播放器请求时需要带上Referer
+func exportManifests(task pageTask, config *Config) error {
+	parser := NewParser(config)
+	aidStr := fmt.Sprintf("%d", task.Aid)
+	tracks, err := parser.ExtractTracks("", aidStr, aidStr, fmt.Sprintf("%d", task.Cid), "", config.UseTVApi, config.UseIntlApi, config.UseAppApi, "")
+	if err != nil {
+		return err
+	}
+
+	tracks = manifestTracks(tracks, config)
+	if len(tracks) == 0 {
+		return fmt.Errorf("没有可导出的DASH流，FLV分段格式不支持导出清单")
+	}
+	if parser.Duration <= 0 {
+		return fmt.Errorf("播放数据中没有视频时长，无法生成清单")
+	}
+
+	if config.WorkDir != "" {
+		if err := os.MkdirAll(config.WorkDir, 0755); err != nil {
+			return fmt.Errorf("创建工作目录失败: %w", err)
+		}
+	}
+	basePath := filepath.Join(config.WorkDir, task.fileName())
+
+	if config.ExportMPD {
+		data, err := buildMPD(tracks, parser.Duration)
+		if err != nil {
+			return err
+		}
+		mpdPath := basePath + ".mpd"
+		if err := os.WriteFile(mpdPath, data, 0644); err != nil {
+			return fmt.Errorf("写入MPD失败: %w", err)
+		}
+		fmt.Printf("MPD已保存: %s\n", mpdPath)
+	}
+
+	if config.ExportHLS {
+		if err := writeHLS(parser.HttpClient, tracks, basePath); err != nil {
+			return err
+		}
+		fmt.Printf("HLS播放列表已保存: %s.m3u8\n", basePath)
+	}
+	printManifestNotice(tracks)
+	return nil
+}
+
+// printManifestNotice 提示清单中的CDN地址有时效，且播放器请求时需要带上Referer
+func printManifestNotice(tracks []*Track) {
+	expiry := "有效期通常只有几个小时"
+	if deadline, ok := manifestDeadline(tracks); ok {
+		expiry = "将于 " + deadline.Format("2006-01-02 15:04:05") + " 过期"
+	}
+	fmt.Printf("注意：清单直接引用B站CDN地址，%s，过期后需要重新导出\n", expiry)
+	fmt.Println("注意：播放器请求CDN地址时需要带上 Referer: https://www.bilibili.com/，否则会返回403")
+}
+
+// manifestDeadline 返回清单中最早过期的地址的过期时间，取自CDN地址的deadline参数
+func manifestDeadline(tracks []*Track) (time.Time, bool) {
+	var earliest int64
+	for _, track := range tracks {
+		u, err := url.Parse(track.URL)
+		if err != nil {
+			continue
+		}
+		deadline, err := strconv.ParseInt(u.Query().Get("deadline"), 10, 64)
+		if err != nil || deadline <= 0 {
+			continue
+		}
+		if earliest == 0 || deadline < earliest {
+			earliest = deadline
+		}
+	}
+	if earliest == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(earliest, 0), true
+}
+
+// manifestTracks 返回可写入清单的DASH轨道，按仅视频/仅音频选项过滤
+func manifestTracks(tracks []*Track, config *Config) []*Track {
+	var result []*Track
+	for _, track := range tracks {
+		if track.SegmentBase == nil || len(track.Segments) > 0 {
+			continue
+		}
+		if (track.FrameType == "video" && config.AudioOnly) || (track.FrameType == "audio" && config.VideoOnly) {
+			continue
+		}
+		result = append(result, track)
+	}
+	return result
+}
+
+// buildMPD 生成MPD清单，视频按编码、音频按编码和语言分为不同的AdaptationSet
+func buildMPD(tracks []*Track, duration time.Duration) ([]byte, error) {
+	manifest := mpdManifest{
+		Xmlns:                     "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:                  "urn:mpeg:dash:profile:isoff-on-demand:2011",
+		Type:                      "static",
+		MediaPresentationDuration: isoDuration(duration),
+		MinBufferTime:             "PT1.5S",
+		Period:                    mpdPeriod{ID: "0", Start: "PT0S"},
+	}
+
+	setIndex := make(map[string]int)
+	for _, track := range tracks {
+		key := track.FrameType + "/" + track.Codec + "/" + track.Language
+		i, ok := setIndex[key]
+		if !ok {
+			i = len(manifest.Period.AdaptationSets)
+			setIndex[key] = i
+			manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, mpdAdaptationSet{
+				ID:                      i,
+				ContentType:             track.FrameType,
+				MimeType:                trackMimeType(track),
+				Lang:                    track.Language,
+				SubsegmentAlignment:     true,
+				SubsegmentStartsWithSAP: 1,
+			})
+		}
+
+		representation := mpdRepresentation{
+			ID:        manifestTrackID(track),
+			Bandwidth: track.Bandwidth * 1000,
+			Codecs:    track.Codecs,
+			BaseURL:   track.URL,
+		}
+		if track.FrameType == "video" {
+			representation.Width = track.Width
+			representation.Height = track.Height
+			representation.FrameRate = mpdFrameRate(track.FrameRate)
+			representation.Sar = track.Sar
+		}
+		representation.SegmentBase.IndexRange = track.SegmentBase.IndexRange
+		representation.SegmentBase.IndexRangeExact = true
+		representation.SegmentBase.Initialization.Range = track.SegmentBase.Initialization
+
+		set := &manifest.Period.AdaptationSets[i]
+		set.Representations = append(set.Representations, representation)
+	}
+
+	data, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("生成MPD失败: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// writeHLS 生成HLS主播放列表和每个轨道的媒体播放列表
+// 媒体播放列表使用fMP4和字节范围，分段信息从sidx索引读取
+func writeHLS(client *HTTPClient, tracks []*Track, basePath string) error {
+	var videos, audios []*Track
+	for _, track := range tracks {
+		playlist, err := buildMediaPlaylist(client, track)
+		if err != nil {
+			return fmt.Errorf("生成%s的HLS播放列表失败: %w", manifestTrackID(track), err)
+		}
+		if err := os.WriteFile(mediaPlaylistPath(basePath, track), []byte(playlist), 0644); err != nil {
+			return fmt.Errorf("写入HLS播放列表失败: %w", err)
+		}
+		if track.FrameType == "video" {
+			videos = append(videos, track)
+		} else {
+			audios = append(audios, track)
+		}
+	}
+
+	master := buildMasterPlaylist(videos, audios, filepath.Base(basePath))
+	if err := os.WriteFile(basePath+".m3u8", []byte(master), 0644); err != nil {
+		return fmt.Errorf("写入HLS播放列表失败: %w", err)
+	}
+	return nil
+}
+
+// buildMediaPlaylist 生成单个轨道的媒体播放列表
+func buildMediaPlaylist(client *HTTPClient, track *Track) (string, error) {
+	initRange, err := parseByteRange(track.SegmentBase.Initialization)
+	if err != nil {
+		return "", err
+	}
+	segments, err := fetchSegmentIndex(client, track.URL, track)
+	if err != nil {
+		return "", err
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("sidx中没有分段")
+	}
+
+	var maxDuration time.Duration
+	for _, segment := range segments {
+		if segment.Duration > maxDuration {
+			maxDuration = segment.Duration
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(maxDuration.Seconds())))
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-MEDIA-SEQUENCE:0\n")
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\",BYTERANGE=\"%d@%d\"\n", track.URL, initRange.Length(), initRange.Start)
+	for _, segment := range segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", segment.Duration.Seconds())
+		fmt.Fprintf(&b, "#EXT-X-BYTERANGE:%d@%d\n", segment.Range.Length(), segment.Range.Start)
+		b.WriteString(track.URL + "\n")
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String(), nil
+}
+
+// buildMasterPlaylist 生成HLS主播放列表，音频按编码分组，每组与每路视频组合为一个变体
+func buildMasterPlaylist(videos, audios []*Track, baseName string) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	// 音频分组
+	var groups []string
+	groupTracks := make(map[string][]*Track)
+	for _, audio := range audios {
+		group := "audio-" + strings.ToLower(audio.Codec)
+		if _, ok := groupTracks[group]; !ok {
+			groups = append(groups, group)
+		}
+		groupTracks[group] = append(groupTracks[group], audio)
+	}
+	for _, group := range groups {
+		for i, audio := range groupTracks[group] {
+			isDefault := "NO"
+			if i == 0 {
+				isDefault = "YES"
+			}
+			// 同一分组内NAME不能重复
+			name := audio.Description
+			if audio.Language != "" {
+				name += " [" + audio.Language + "]"
+			}
+			fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"%s\",NAME=\"%s\",", group, name)
+			if audio.Language != "" {
+				fmt.Fprintf(&b, "LANGUAGE=\"%s\",", audio.Language)
+			}
+			fmt.Fprintf(&b, "DEFAULT=%s,AUTOSELECT=YES,URI=\"%s\"\n", isDefault, filepath.Base(mediaPlaylistPath(baseName, audio)))
+		}
+	}
+
+	// 只有音频时每路音频作为一个变体
+	if len(videos) == 0 {
+		for _, audio := range audios {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n", audio.Bandwidth*1000, audio.Codecs)
+			b.WriteString(filepath.Base(mediaPlaylistPath(baseName, audio)) + "\n")
+		}
+		return b.String()
+	}
+
+	for _, video := range videos {
+		variants := groups
+		if len(variants) == 0 {
+			variants = []string{""}
+		}
+		for _, group := range variants {
+			bandwidth := video.Bandwidth * 1000
+			codecs := video.Codecs
+			if group != "" {
+				audio := groupTracks[group][0]
+				for _, track := range groupTracks[group] {
+					if track.Bandwidth > audio.Bandwidth {
+						audio = track
+					}
+				}
+				bandwidth += audio.Bandwidth * 1000
+				codecs += "," + audio.Codecs
+			}
+
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\",RESOLUTION=%dx%d", bandwidth, codecs, video.Width, video.Height)
+			if video.FrameRate != "" {
+				fmt.Fprintf(&b, ",FRAME-RATE=%s", video.FrameRate)
+			}
+			if group != "" {
+				fmt.Fprintf(&b, ",AUDIO=\"%s\"", group)
+			}
+			b.WriteString("\n" + filepath.Base(mediaPlaylistPath(baseName, video)) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// manifestTrackID 清单中区分轨道的ID，同一画质可能有多种编码
+func manifestTrackID(track *Track) string {
+	id := fmt.Sprintf("%s_%d_%s", track.FrameType, track.ID, strings.ToLower(track.Codec))
+	if track.Language != "" {
+		id += "_" + track.Language
+	}
+	return id
+}
+
+// mediaPlaylistPath 轨道的HLS媒体播放列表路径
+func mediaPlaylistPath(basePath string, track *Track) string {
+	return basePath + "." + manifestTrackID(track) + ".m3u8"
+}
+
+// trackMimeType 轨道的MIME类型，接口未返回时按轨道类型推断
+func trackMimeType(track *Track) string {
+	if track.MimeType != "" {
+		return track.MimeType
+	}
+	return track.FrameType + "/mp4"
+}
+
+// mpdFrameRate MPD的frameRate只允许整数或分数，如 29.970 写为 30000/1001
+func mpdFrameRate(frameRate string) string {
+	fps, err := strconv.ParseFloat(frameRate, 64)
+	if err != nil || fps <= 0 {
+		return ""
+	}
+	if fps == math.Trunc(fps) {
+		return strconv.Itoa(int(fps))
+	}
+	// NTSC帧率
+	if ntsc := math.Round(fps * 1.001); math.Abs(ntsc/1.001-fps) < 0.01 {
+		return fmt.Sprintf("%d/1001", int(ntsc)*1000)
+	}
+	return fmt.Sprintf("%d/1000", int(math.Round(fps*1000)))
+}
+
+// isoDuration 将时长格式化为ISO 8601格式，如 PT90.500S
+func isoDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%.3fS", d.Seconds())
+}
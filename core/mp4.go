@@ -0,0 +1,587 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// mp4Box MP4盒子在文件中的位置
+type mp4Box struct {
+	Type   string
+	Offset int64 // 盒子起始位置
+	Size   int64 // 盒子总大小，包含头部
+	Header int64 // 头部大小，8或16字节
+}
+
+// dataOffset 返回盒子内容的起始位置
+func (b mp4Box) dataOffset() int64 {
+	return b.Offset + b.Header
+}
+
+// end 返回盒子的结束位置
+func (b mp4Box) end() int64 {
+	return b.Offset + b.Size
+}
+
+// readMP4Boxes 读取[start, end)范围内的同级盒子
+func readMP4Boxes(r io.ReaderAt, start, end int64) ([]mp4Box, error) {
+	var boxes []mp4Box
+	var header [16]byte
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, fmt.Errorf("读取盒子头部失败: %w", err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// 大小为0表示延伸到文件末尾
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, fmt.Errorf("读取盒子头部失败: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > end {
+			return nil, fmt.Errorf("无效的%s盒子大小: %d", boxType, size)
+		}
+		boxes = append(boxes, mp4Box{Type: boxType, Offset: offset, Size: size, Header: headerSize})
+		offset += size
+	}
+	return boxes, nil
+}
+
+// readMP4Children 读取盒子的子盒子
+func readMP4Children(r io.ReaderAt, box mp4Box) ([]mp4Box, error) {
+	return readMP4Boxes(r, box.dataOffset(), box.end())
+}
+
+// readMP4BoxData 读取盒子内容，不包含头部
+func readMP4BoxData(r io.ReaderAt, box mp4Box) ([]byte, error) {
+	data := make([]byte, box.Size-box.Header)
+	if _, err := r.ReadAt(data, box.dataOffset()); err != nil {
+		return nil, fmt.Errorf("读取%s盒子失败: %w", box.Type, err)
+	}
+	return data, nil
+}
+
+// readMP4BoxRaw 读取完整盒子，包含头部
+func readMP4BoxRaw(r io.ReaderAt, box mp4Box) ([]byte, error) {
+	data := make([]byte, box.Size)
+	if _, err := r.ReadAt(data, box.Offset); err != nil {
+		return nil, fmt.Errorf("读取%s盒子失败: %w", box.Type, err)
+	}
+	return data, nil
+}
+
+// findMP4Box 在同级盒子中查找指定类型的第一个盒子
+func findMP4Box(boxes []mp4Box, boxType string) (mp4Box, bool) {
+	for _, box := range boxes {
+		if box.Type == boxType {
+			return box, true
+		}
+	}
+	return mp4Box{}, false
+}
+
+// findMP4Path 按路径逐级查找盒子，如 findMP4Path(r, boxes, "moov", "trak", "mdia")
+func findMP4Path(r io.ReaderAt, boxes []mp4Box, path ...string) (mp4Box, error) {
+	var box mp4Box
+	for i, boxType := range path {
+		found, ok := findMP4Box(boxes, boxType)
+		if !ok {
+			return mp4Box{}, fmt.Errorf("未找到%s盒子", boxType)
+		}
+		box = found
+		if i < len(path)-1 {
+			children, err := readMP4Children(r, box)
+			if err != nil {
+				return mp4Box{}, err
+			}
+			boxes = children
+		}
+	}
+	return box, nil
+}
+
+// mp4Sample 媒体样本
+type mp4Sample struct {
+	Size     uint32
+	Duration uint32
+	CTO      int32 // 合成时间偏移
+	Sync     bool  // 是否为关键帧
+}
+
+// mp4Chunk 连续存放的一组样本，对应fMP4中的一个trun
+type mp4Chunk struct {
+	Offset      int64  // 数据在源文件中的位置
+	Size        int64  // 数据大小
+	FirstSample int    // 第一个样本的序号
+	Count       int    // 样本数量
+	StartTime   uint64 // 第一个样本的解码时间
+}
+
+// mp4TrackDefaults trex/tfhd中的样本默认值
+type mp4TrackDefaults struct {
+	Duration uint32
+	Size     uint32
+	Flags    uint32
+}
+
+// fragmentedTrack fMP4文件中的单个轨道
+type fragmentedTrack struct {
+	file *os.File
+
+	TrackID   uint32
+	Handler   string // vide 或 soun
+	Timescale uint32
+
+	tkhd []byte   // tkhd盒子内容，不包含头部
+	mdhd []byte   // mdhd盒子内容，不包含头部
+	hdlr []byte   // 原始hdlr盒子
+	minf [][]byte // minf中除stbl外的原始盒子
+	stsd []byte   // 原始stsd盒子
+
+	Samples []mp4Sample
+	Chunks  []mp4Chunk
+}
+
+// duration 返回轨道总时长（以轨道时间刻度为单位）
+func (t *fragmentedTrack) duration() uint64 {
+	var total uint64
+	for _, sample := range t.Samples {
+		total += uint64(sample.Duration)
+	}
+	return total
+}
+
+// openFragmentedTrack 打开fMP4文件并解析其中的轨道和样本
+func openFragmentedTrack(path string) (*fragmentedTrack, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	track, err := parseFragmentedTrack(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("解析%s失败: %w", path, err)
+	}
+	track.file = file
+	return track, nil
+}
+
+// parseFragmentedTrack 解析fMP4的轨道信息和所有分片
+func parseFragmentedTrack(file *os.File) (*fragmentedTrack, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	boxes, err := readMP4Boxes(file, 0, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	moov, ok := findMP4Box(boxes, "moov")
+	if !ok {
+		return nil, fmt.Errorf("未找到moov盒子")
+	}
+	moovData, err := readMP4BoxRaw(file, moov)
+	if err != nil {
+		return nil, err
+	}
+
+	track, defaults, err := parseMoov(moovData)
+	if err != nil {
+		return nil, err
+	}
+
+	var decodeTime uint64
+	for _, box := range boxes {
+		if box.Type != "moof" {
+			continue
+		}
+		moofData, err := readMP4BoxRaw(file, box)
+		if err != nil {
+			return nil, err
+		}
+		decodeTime, err = track.parseMoof(moofData, box.Offset, defaults, decodeTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(track.Samples) == 0 {
+		return nil, fmt.Errorf("不是分片MP4文件或没有媒体样本")
+	}
+	return track, nil
+}
+
+// parseMoov 解析moov中的第一个轨道及其trex默认值
+func parseMoov(moovData []byte) (*fragmentedTrack, mp4TrackDefaults, error) {
+	var defaults mp4TrackDefaults
+	r := bytes.NewReader(moovData)
+
+	root, err := readMP4Boxes(r, 0, int64(len(moovData)))
+	if err != nil {
+		return nil, defaults, err
+	}
+	moovChildren, err := readMP4Children(r, root[0])
+	if err != nil {
+		return nil, defaults, err
+	}
+
+	trak, ok := findMP4Box(moovChildren, "trak")
+	if !ok {
+		return nil, defaults, fmt.Errorf("未找到trak盒子")
+	}
+	trakChildren, err := readMP4Children(r, trak)
+	if err != nil {
+		return nil, defaults, err
+	}
+
+	track := &fragmentedTrack{}
+
+	tkhd, err := findMP4Path(r, trakChildren, "tkhd")
+	if err != nil {
+		return nil, defaults, err
+	}
+	if track.tkhd, err = readMP4BoxData(r, tkhd); err != nil {
+		return nil, defaults, err
+	}
+	if trackID, ok := fullBoxField32(track.tkhd, 12, 20); ok {
+		track.TrackID = trackID
+	} else {
+		return nil, defaults, fmt.Errorf("tkhd盒子过短")
+	}
+
+	mdia, err := findMP4Path(r, trakChildren, "mdia")
+	if err != nil {
+		return nil, defaults, err
+	}
+	mdiaChildren, err := readMP4Children(r, mdia)
+	if err != nil {
+		return nil, defaults, err
+	}
+
+	mdhd, err := findMP4Path(r, mdiaChildren, "mdhd")
+	if err != nil {
+		return nil, defaults, err
+	}
+	if track.mdhd, err = readMP4BoxData(r, mdhd); err != nil {
+		return nil, defaults, err
+	}
+	if timescale, ok := fullBoxField32(track.mdhd, 12, 20); ok && timescale > 0 {
+		track.Timescale = timescale
+	} else {
+		return nil, defaults, fmt.Errorf("无效的mdhd时间刻度")
+	}
+
+	hdlr, err := findMP4Path(r, mdiaChildren, "hdlr")
+	if err != nil {
+		return nil, defaults, err
+	}
+	if track.hdlr, err = readMP4BoxRaw(r, hdlr); err != nil {
+		return nil, defaults, err
+	}
+	if len(track.hdlr) >= int(hdlr.Header)+12 {
+		track.Handler = string(track.hdlr[hdlr.Header+8 : hdlr.Header+12])
+	}
+
+	minf, err := findMP4Path(r, mdiaChildren, "minf")
+	if err != nil {
+		return nil, defaults, err
+	}
+	minfChildren, err := readMP4Children(r, minf)
+	if err != nil {
+		return nil, defaults, err
+	}
+	for _, child := range minfChildren {
+		if child.Type == "stbl" {
+			continue
+		}
+		raw, err := readMP4BoxRaw(r, child)
+		if err != nil {
+			return nil, defaults, err
+		}
+		track.minf = append(track.minf, raw)
+	}
+
+	stsd, err := findMP4Path(r, minfChildren, "stbl", "stsd")
+	if err != nil {
+		return nil, defaults, err
+	}
+	if track.stsd, err = readMP4BoxRaw(r, stsd); err != nil {
+		return nil, defaults, err
+	}
+
+	// trex中的默认样本值
+	if mvex, ok := findMP4Box(moovChildren, "mvex"); ok {
+		mvexChildren, err := readMP4Children(r, mvex)
+		if err != nil {
+			return nil, defaults, err
+		}
+		for _, trex := range mvexChildren {
+			if trex.Type != "trex" {
+				continue
+			}
+			data, err := readMP4BoxData(r, trex)
+			if err != nil {
+				return nil, defaults, err
+			}
+			if len(data) < 24 || binary.BigEndian.Uint32(data[4:8]) != track.TrackID {
+				continue
+			}
+			defaults.Duration = binary.BigEndian.Uint32(data[12:16])
+			defaults.Size = binary.BigEndian.Uint32(data[16:20])
+			defaults.Flags = binary.BigEndian.Uint32(data[20:24])
+		}
+	}
+
+	return track, defaults, nil
+}
+
+// parseMoof 解析一个moof分片，追加样本和数据块，返回下一个分片的解码时间
+func (t *fragmentedTrack) parseMoof(moofData []byte, moofOffset int64, trex mp4TrackDefaults, decodeTime uint64) (uint64, error) {
+	r := bytes.NewReader(moofData)
+	root, err := readMP4Boxes(r, 0, int64(len(moofData)))
+	if err != nil {
+		return 0, err
+	}
+	trafs, err := readMP4Children(r, root[0])
+	if err != nil {
+		return 0, err
+	}
+
+	for _, traf := range trafs {
+		if traf.Type != "traf" {
+			continue
+		}
+		children, err := readMP4Children(r, traf)
+		if err != nil {
+			return 0, err
+		}
+
+		// tfhd: 轨道ID、数据基址和默认值
+		tfhdBox, ok := findMP4Box(children, "tfhd")
+		if !ok {
+			return 0, fmt.Errorf("未找到tfhd盒子")
+		}
+		tfhd, err := readMP4BoxData(r, tfhdBox)
+		if err != nil {
+			return 0, err
+		}
+		if len(tfhd) < 8 {
+			return 0, fmt.Errorf("tfhd盒子过短")
+		}
+		if binary.BigEndian.Uint32(tfhd[4:8]) != t.TrackID {
+			continue
+		}
+		flags := binary.BigEndian.Uint32(tfhd[0:4]) & 0xFFFFFF
+		defaults := trex
+		baseOffset := moofOffset
+		p := 8
+		field := func(size int) (uint64, error) {
+			if p+size > len(tfhd) {
+				return 0, fmt.Errorf("tfhd盒子过短")
+			}
+			var v uint64
+			if size == 8 {
+				v = binary.BigEndian.Uint64(tfhd[p:])
+			} else {
+				v = uint64(binary.BigEndian.Uint32(tfhd[p:]))
+			}
+			p += size
+			return v, nil
+		}
+		if flags&0x01 != 0 {
+			v, err := field(8)
+			if err != nil {
+				return 0, err
+			}
+			baseOffset = int64(v)
+		}
+		if flags&0x02 != 0 {
+			if _, err := field(4); err != nil {
+				return 0, err
+			}
+		}
+		if flags&0x08 != 0 {
+			v, err := field(4)
+			if err != nil {
+				return 0, err
+			}
+			defaults.Duration = uint32(v)
+		}
+		if flags&0x10 != 0 {
+			v, err := field(4)
+			if err != nil {
+				return 0, err
+			}
+			defaults.Size = uint32(v)
+		}
+		if flags&0x20 != 0 {
+			v, err := field(4)
+			if err != nil {
+				return 0, err
+			}
+			defaults.Flags = uint32(v)
+		}
+
+		// tfdt: 分片的解码起始时间
+		if tfdtBox, ok := findMP4Box(children, "tfdt"); ok {
+			tfdt, err := readMP4BoxData(r, tfdtBox)
+			if err != nil {
+				return 0, err
+			}
+			if len(tfdt) >= 12 && tfdt[0] == 1 {
+				decodeTime = binary.BigEndian.Uint64(tfdt[4:12])
+			} else if len(tfdt) >= 8 {
+				decodeTime = uint64(binary.BigEndian.Uint32(tfdt[4:8]))
+			}
+		}
+
+		dataOffset := baseOffset
+		for _, trunBox := range children {
+			if trunBox.Type != "trun" {
+				continue
+			}
+			trun, err := readMP4BoxData(r, trunBox)
+			if err != nil {
+				return 0, err
+			}
+			dataOffset, decodeTime, err = t.parseTrun(trun, baseOffset, dataOffset, defaults, decodeTime)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return decodeTime, nil
+}
+
+// parseTrun 解析trun中的样本，返回下一个trun的数据位置和解码时间
+func (t *fragmentedTrack) parseTrun(trun []byte, baseOffset, dataOffset int64, defaults mp4TrackDefaults, decodeTime uint64) (int64, uint64, error) {
+	if len(trun) < 8 {
+		return 0, 0, fmt.Errorf("trun盒子过短")
+	}
+	flags := binary.BigEndian.Uint32(trun[0:4]) & 0xFFFFFF
+	count := int(binary.BigEndian.Uint32(trun[4:8]))
+	p := 8
+
+	if flags&0x01 != 0 {
+		if p+4 > len(trun) {
+			return 0, 0, fmt.Errorf("trun盒子过短")
+		}
+		dataOffset = baseOffset + int64(int32(binary.BigEndian.Uint32(trun[p:])))
+		p += 4
+	}
+	firstFlags, hasFirstFlags := uint32(0), flags&0x04 != 0
+	if hasFirstFlags {
+		if p+4 > len(trun) {
+			return 0, 0, fmt.Errorf("trun盒子过短")
+		}
+		firstFlags = binary.BigEndian.Uint32(trun[p:])
+		p += 4
+	}
+
+	entrySize := 0
+	for _, bit := range []uint32{0x100, 0x200, 0x400, 0x800} {
+		if flags&bit != 0 {
+			entrySize += 4
+		}
+	}
+	if count < 0 || p+count*entrySize > len(trun) {
+		return 0, 0, fmt.Errorf("trun样本数量无效: %d", count)
+	}
+
+	chunk := mp4Chunk{Offset: dataOffset, FirstSample: len(t.Samples), Count: count, StartTime: decodeTime}
+	for i := 0; i < count; i++ {
+		sample := mp4Sample{Duration: defaults.Duration, Size: defaults.Size}
+		sampleFlags := defaults.Flags
+		if flags&0x100 != 0 {
+			sample.Duration = binary.BigEndian.Uint32(trun[p:])
+			p += 4
+		}
+		if flags&0x200 != 0 {
+			sample.Size = binary.BigEndian.Uint32(trun[p:])
+			p += 4
+		}
+		if flags&0x400 != 0 {
+			sampleFlags = binary.BigEndian.Uint32(trun[p:])
+			p += 4
+		}
+		if i == 0 && hasFirstFlags {
+			sampleFlags = firstFlags
+		}
+		if flags&0x800 != 0 {
+			// 版本0为无符号偏移，实际不会超过int32范围
+			sample.CTO = int32(binary.BigEndian.Uint32(trun[p:]))
+			p += 4
+		}
+		// sample_is_non_sync_sample 标志位
+		sample.Sync = sampleFlags&0x00010000 == 0
+
+		t.Samples = append(t.Samples, sample)
+		chunk.Size += int64(sample.Size)
+		decodeTime += uint64(sample.Duration)
+	}
+
+	if count > 0 {
+		t.Chunks = append(t.Chunks, chunk)
+	}
+	return dataOffset + chunk.Size, decodeTime, nil
+}
+
+// fullBoxField32 读取全盒子中的32位字段，v0Offset和v1Offset分别为版本0和版本1时的偏移
+func fullBoxField32(data []byte, v0Offset, v1Offset int) (uint32, bool) {
+	if len(data) < 1 {
+		return 0, false
+	}
+	offset := v0Offset
+	if data[0] == 1 {
+		offset = v1Offset
+	}
+	if len(data) < offset+4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(data[offset:]), true
+}
+
+// probeSampleEntry 读取MP4文件第一个轨道的样本描述类型，如 avc1、hev1、mp4a、ec-3、fLaC、Opus
+func probeSampleEntry(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	boxes, err := readMP4Boxes(file, 0, info.Size())
+	if err != nil {
+		return "", err
+	}
+	stsd, err := findMP4Path(file, boxes, "moov", "trak", "mdia", "minf", "stbl", "stsd")
+	if err != nil {
+		return "", err
+	}
+	data, err := readMP4BoxData(file, stsd)
+	if err != nil {
+		return "", err
+	}
+	// 版本和标志(4) + 条目数(4) + 第一个条目的大小(4)和类型(4)
+	if len(data) < 16 || binary.BigEndian.Uint32(data[4:8]) == 0 {
+		return "", fmt.Errorf("stsd盒子中没有样本描述")
+	}
+	return string(data[12:16]), nil
+}
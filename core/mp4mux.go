@@ -0,0 +1,413 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// movieTimescale 输出文件mvhd使用的时间刻度
+const movieTimescale = 1000
+
+// MP4MuxOptions 内置MP4混流选项
+type MP4MuxOptions struct {
+	FastStart bool     // 将moov放在mdat之前，便于边下边播
+	Languages []string // 与输入文件一一对应的ISO 639-2语言代码，为空时保留原值
+}
+
+// MuxMP4 使用内置混流器将多个单轨道的fMP4文件（如DASH的视频和音频流）合并为普通MP4文件
+// 样本数据原样复制，不重新编码
+func MuxMP4(inputs []string, outputPath string, options MP4MuxOptions) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("没有需要混流的文件")
+	}
+
+	tracks := make([]*fragmentedTrack, 0, len(inputs))
+	defer func() {
+		for _, track := range tracks {
+			track.file.Close()
+		}
+	}()
+	for _, input := range inputs {
+		track, err := openFragmentedTrack(input)
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, track)
+	}
+
+	layout := interleaveChunks(tracks)
+	var payloadSize int64
+	for _, ref := range layout {
+		payloadSize += tracks[ref.track].Chunks[ref.chunk].Size
+	}
+
+	ftyp := buildFtyp()
+	mdatHeaderSize := int64(8)
+	if payloadSize+8 > math.MaxUint32 {
+		mdatHeaderSize = 16
+	}
+
+	// 数据块偏移依赖moov的位置，先用0偏移计算moov大小
+	offsets := make([][]int64, len(tracks))
+	for i, track := range tracks {
+		offsets[i] = make([]int64, len(track.Chunks))
+	}
+	useCo64 := int64(len(ftyp))+mdatHeaderSize+payloadSize > math.MaxUint32
+	dataStart := int64(len(ftyp)) + mdatHeaderSize
+	if options.FastStart {
+		moov := buildMoov(tracks, offsets, options.Languages, useCo64)
+		dataStart += int64(len(moov))
+		if !useCo64 && dataStart+payloadSize > math.MaxUint32 {
+			useCo64 = true
+			dataStart += int64(len(buildMoov(tracks, offsets, options.Languages, true)) - len(moov))
+		}
+	}
+
+	position := dataStart
+	for _, ref := range layout {
+		offsets[ref.track][ref.chunk] = position
+		position += tracks[ref.track].Chunks[ref.chunk].Size
+	}
+	moov := buildMoov(tracks, offsets, options.Languages, useCo64)
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	if _, err := w.Write(ftyp); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if options.FastStart {
+		if _, err := w.Write(moov); err != nil {
+			return fmt.Errorf("写入文件失败: %w", err)
+		}
+	}
+
+	if _, err := w.Write(mdatHeader(payloadSize, mdatHeaderSize)); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	for _, ref := range layout {
+		track := tracks[ref.track]
+		chunk := track.Chunks[ref.chunk]
+		if _, err := io.Copy(w, io.NewSectionReader(track.file, chunk.Offset, chunk.Size)); err != nil {
+			return fmt.Errorf("复制媒体数据失败: %w", err)
+		}
+	}
+
+	if !options.FastStart {
+		if _, err := w.Write(moov); err != nil {
+			return fmt.Errorf("写入文件失败: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	return file.Close()
+}
+
+// chunkRef 交错排列后的数据块引用
+type chunkRef struct {
+	track int
+	chunk int
+}
+
+// interleaveChunks 按解码时间交错排列各轨道的数据块，使播放时读取更连续
+func interleaveChunks(tracks []*fragmentedTrack) []chunkRef {
+	var refs []chunkRef
+	for i, track := range tracks {
+		for j := range track.Chunks {
+			refs = append(refs, chunkRef{track: i, chunk: j})
+		}
+	}
+
+	startTime := func(ref chunkRef) float64 {
+		track := tracks[ref.track]
+		return float64(track.Chunks[ref.chunk].StartTime) / float64(track.Timescale)
+	}
+	sort.SliceStable(refs, func(a, b int) bool {
+		return startTime(refs[a]) < startTime(refs[b])
+	})
+	return refs
+}
+
+// buildFtyp 生成ftyp盒子
+func buildFtyp() []byte {
+	payload := []byte("isom")
+	payload = binary.BigEndian.AppendUint32(payload, 512)
+	payload = append(payload, "isomiso2avc1mp41"...)
+	return makeBox("ftyp", payload)
+}
+
+// mdatHeader 生成mdat盒子头部，数据超过4GB时使用64位大小
+func mdatHeader(payloadSize, headerSize int64) []byte {
+	if headerSize == 16 {
+		header := binary.BigEndian.AppendUint32(nil, 1)
+		header = append(header, "mdat"...)
+		return binary.BigEndian.AppendUint64(header, uint64(payloadSize+16))
+	}
+	header := binary.BigEndian.AppendUint32(nil, uint32(payloadSize+8))
+	return append(header, "mdat"...)
+}
+
+// buildMoov 生成moov盒子
+func buildMoov(tracks []*fragmentedTrack, offsets [][]int64, languages []string, useCo64 bool) []byte {
+	var movieDuration uint64
+	for _, track := range tracks {
+		movieDuration = max(movieDuration, toMovieTime(track.duration(), track.Timescale))
+	}
+
+	children := [][]byte{buildMvhd(movieDuration, uint32(len(tracks)+1))}
+	for i, track := range tracks {
+		language := ""
+		if i < len(languages) {
+			language = languages[i]
+		}
+		children = append(children, buildTrak(track, uint32(i+1), offsets[i], language, useCo64))
+	}
+	return makeBox("moov", children...)
+}
+
+// buildMvhd 生成mvhd盒子
+func buildMvhd(duration uint64, nextTrackID uint32) []byte {
+	var payload []byte
+	version := byte(0)
+	if duration > math.MaxUint32 {
+		version = 1
+		payload = make([]byte, 16) // 创建和修改时间
+		payload = binary.BigEndian.AppendUint32(payload, movieTimescale)
+		payload = binary.BigEndian.AppendUint64(payload, duration)
+	} else {
+		payload = make([]byte, 8)
+		payload = binary.BigEndian.AppendUint32(payload, movieTimescale)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(duration))
+	}
+	payload = binary.BigEndian.AppendUint32(payload, 0x00010000) // 播放速率1.0
+	payload = binary.BigEndian.AppendUint16(payload, 0x0100)     // 音量1.0
+	payload = append(payload, make([]byte, 10)...)
+	payload = append(payload, identityMatrix()...)
+	payload = append(payload, make([]byte, 24)...)
+	payload = binary.BigEndian.AppendUint32(payload, nextTrackID)
+	return makeFullBox("mvhd", version, 0, payload)
+}
+
+// buildTrak 生成trak盒子，tkhd、mdhd、hdlr和stsd沿用源文件，样本表重新生成
+func buildTrak(track *fragmentedTrack, trackID uint32, offsets []int64, language string, useCo64 bool) []byte {
+	duration := track.duration()
+	movieDuration := toMovieTime(duration, track.Timescale)
+
+	children := [][]byte{makeBox("tkhd", patchTkhd(track.tkhd, trackID, movieDuration))}
+
+	// 存在B帧时第一帧的合成时间不为0，用编辑列表对齐音视频
+	if len(track.Samples) > 0 && track.Samples[0].CTO > 0 {
+		children = append(children, buildEdts(movieDuration, track.Samples[0].CTO))
+	}
+
+	minf := append([][]byte{}, track.minf...)
+	minf = append(minf, buildStbl(track, offsets, useCo64))
+
+	mdia := makeBox("mdia",
+		makeBox("mdhd", patchMdhd(track.mdhd, duration, language)),
+		track.hdlr,
+		makeBox("minf", minf...),
+	)
+	children = append(children, mdia)
+	return makeBox("trak", children...)
+}
+
+// patchTkhd 更新tkhd中的轨道ID和时长，并将轨道标记为启用
+func patchTkhd(tkhd []byte, trackID uint32, duration uint64) []byte {
+	data := append([]byte{}, tkhd...)
+	data[1], data[2], data[3] = 0, 0, 0x03
+	if data[0] == 1 && len(data) >= 36 {
+		binary.BigEndian.PutUint32(data[20:], trackID)
+		binary.BigEndian.PutUint64(data[28:], duration)
+	} else if len(data) >= 24 {
+		binary.BigEndian.PutUint32(data[12:], trackID)
+		binary.BigEndian.PutUint32(data[20:], clampUint32(duration))
+	}
+	return data
+}
+
+// patchMdhd 更新mdhd中的时长，language不为空时同时更新语言代码
+func patchMdhd(mdhd []byte, duration uint64, language string) []byte {
+	data := append([]byte{}, mdhd...)
+	languageOffset := 20
+	if data[0] == 1 && len(data) >= 34 {
+		binary.BigEndian.PutUint64(data[24:], duration)
+		languageOffset = 32
+	} else if len(data) >= 22 {
+		binary.BigEndian.PutUint32(data[16:], clampUint32(duration))
+	}
+	if len(language) == 3 && len(data) >= languageOffset+2 {
+		binary.BigEndian.PutUint16(data[languageOffset:], packLanguage(language))
+	}
+	return data
+}
+
+// packLanguage 将ISO 639-2语言代码打包为mdhd使用的15位格式
+func packLanguage(language string) uint16 {
+	var packed uint16
+	for i := 0; i < 3; i++ {
+		packed = packed<<5 | uint16(language[i]-0x60)&0x1F
+	}
+	return packed
+}
+
+// buildEdts 生成跳过初始合成偏移的编辑列表
+func buildEdts(movieDuration uint64, mediaTime int32) []byte {
+	payload := binary.BigEndian.AppendUint32(nil, 1)
+	payload = binary.BigEndian.AppendUint32(payload, clampUint32(movieDuration))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(mediaTime))
+	payload = binary.BigEndian.AppendUint32(payload, 0x00010000)
+	return makeBox("edts", makeFullBox("elst", 0, 0, payload))
+}
+
+// buildStbl 根据样本和数据块生成样本表
+func buildStbl(track *fragmentedTrack, offsets []int64, useCo64 bool) []byte {
+	samples := track.Samples
+	children := [][]byte{track.stsd}
+
+	// stts: 解码时长，连续相同的值合并为一项
+	var stts []byte
+	var sttsCount uint32
+	for i := 0; i < len(samples); {
+		j := i
+		for j < len(samples) && samples[j].Duration == samples[i].Duration {
+			j++
+		}
+		stts = binary.BigEndian.AppendUint32(stts, uint32(j-i))
+		stts = binary.BigEndian.AppendUint32(stts, samples[i].Duration)
+		sttsCount++
+		i = j
+	}
+	children = append(children, makeFullBox("stts", 0, 0, binary.BigEndian.AppendUint32(nil, sttsCount), stts))
+
+	// ctts: 合成时间偏移，全部为0时省略
+	hasCTO, negativeCTO := false, false
+	for _, sample := range samples {
+		hasCTO = hasCTO || sample.CTO != 0
+		negativeCTO = negativeCTO || sample.CTO < 0
+	}
+	if hasCTO {
+		var ctts []byte
+		var cttsCount uint32
+		for i := 0; i < len(samples); {
+			j := i
+			for j < len(samples) && samples[j].CTO == samples[i].CTO {
+				j++
+			}
+			ctts = binary.BigEndian.AppendUint32(ctts, uint32(j-i))
+			ctts = binary.BigEndian.AppendUint32(ctts, uint32(samples[i].CTO))
+			cttsCount++
+			i = j
+		}
+		version := byte(0)
+		if negativeCTO {
+			version = 1
+		}
+		children = append(children, makeFullBox("ctts", version, 0, binary.BigEndian.AppendUint32(nil, cttsCount), ctts))
+	}
+
+	// stss: 关键帧列表，全部为关键帧时省略
+	var stss []byte
+	var syncCount uint32
+	for i, sample := range samples {
+		if sample.Sync {
+			stss = binary.BigEndian.AppendUint32(stss, uint32(i+1))
+			syncCount++
+		}
+	}
+	if int(syncCount) != len(samples) {
+		children = append(children, makeFullBox("stss", 0, 0, binary.BigEndian.AppendUint32(nil, syncCount), stss))
+	}
+
+	// stsc: 每个数据块的样本数，连续相同的值合并为一项
+	var stsc []byte
+	var stscCount uint32
+	for i, chunk := range track.Chunks {
+		if i > 0 && track.Chunks[i-1].Count == chunk.Count {
+			continue
+		}
+		stsc = binary.BigEndian.AppendUint32(stsc, uint32(i+1))
+		stsc = binary.BigEndian.AppendUint32(stsc, uint32(chunk.Count))
+		stsc = binary.BigEndian.AppendUint32(stsc, 1)
+		stscCount++
+	}
+	children = append(children, makeFullBox("stsc", 0, 0, binary.BigEndian.AppendUint32(nil, stscCount), stsc))
+
+	// stsz: 样本大小
+	stsz := binary.BigEndian.AppendUint32(nil, 0)
+	stsz = binary.BigEndian.AppendUint32(stsz, uint32(len(samples)))
+	for _, sample := range samples {
+		stsz = binary.BigEndian.AppendUint32(stsz, sample.Size)
+	}
+	children = append(children, makeFullBox("stsz", 0, 0, stsz))
+
+	// stco/co64: 数据块在输出文件中的位置
+	chunkOffsets := binary.BigEndian.AppendUint32(nil, uint32(len(offsets)))
+	if useCo64 {
+		for _, offset := range offsets {
+			chunkOffsets = binary.BigEndian.AppendUint64(chunkOffsets, uint64(offset))
+		}
+		children = append(children, makeFullBox("co64", 0, 0, chunkOffsets))
+	} else {
+		for _, offset := range offsets {
+			chunkOffsets = binary.BigEndian.AppendUint32(chunkOffsets, uint32(offset))
+		}
+		children = append(children, makeFullBox("stco", 0, 0, chunkOffsets))
+	}
+
+	return makeBox("stbl", children...)
+}
+
+// toMovieTime 将轨道时间转换为mvhd时间刻度
+func toMovieTime(duration uint64, timescale uint32) uint64 {
+	return duration * movieTimescale / uint64(timescale)
+}
+
+// identityMatrix 返回单位变换矩阵
+func identityMatrix() []byte {
+	var matrix []byte
+	for _, v := range []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000} {
+		matrix = binary.BigEndian.AppendUint32(matrix, v)
+	}
+	return matrix
+}
+
+// makeBox 生成盒子，payload依次拼接作为内容
+func makeBox(boxType string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	box := make([]byte, 0, size)
+	box = binary.BigEndian.AppendUint32(box, uint32(size))
+	box = append(box, boxType...)
+	for _, p := range payload {
+		box = append(box, p...)
+	}
+	return box
+}
+
+// makeFullBox 生成带版本和标志的全盒子
+func makeFullBox(boxType string, version byte, flags uint32, payload ...[]byte) []byte {
+	header := binary.BigEndian.AppendUint32(nil, uint32(version)<<24|flags&0xFFFFFF)
+	return makeBox(boxType, append([][]byte{header}, payload...)...)
+}
+
+// clampUint32 将64位时长限制在32位字段范围内
+func clampUint32(v uint64) uint32 {
+	if v > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(v)
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tekintian/go-bbdown/util"
+)
+
+// pageTask 单个分P的下载任务
+type pageTask struct {
+	Aid    int64
+	Cid    int64
+	Bvid   string
+	Title  string // 视频标题，分P标题为空时用作文件名
+	Part   string // 分P标题
+	Index  int    // 分P序号
+	MultiP bool   // 是否为多P视频，多P时文件名添加序号
+}
+
+// fileName 生成输出文件名（不含扩展名）
+func (t pageTask) fileName() string {
+	fileName := t.Part
+	if fileName == "" {
+		// 如果分P标题为空，使用视频标题
+		fileName = t.Title
+	}
+
+	// 如果有多个分P，在文件名中添加序号
+	if t.MultiP {
+		fileName = fmt.Sprintf("%s_P%d", fileName, t.Index)
+	}
+
+	// 清理文件名中的非法字符
+	return util.CleanFilename(fileName)
+}
+
+// tempDirName 临时文件所在的目录名
+const tempDirName = ".bbdown_tmp"
+
+// tempDir 返回任务的临时目录，按aid和cid区分，避免不同视频的同名分P互相覆盖
+// 未指定临时目录时使用工作目录
+func (t pageTask) tempDir(config *Config) string {
+	base := config.TempDir
+	if base == "" {
+		base = config.WorkDir
+	}
+	return filepath.Join(base, tempDirName, fmt.Sprintf("%d_%d", t.Aid, t.Cid))
+}
+
+// archiveID 返回下载记录中使用的视频ID，优先使用BV号
+func (t pageTask) archiveID() string {
+	if t.Bvid != "" {
+		return t.Bvid
+	}
+	return fmt.Sprintf("av%d", t.Aid)
+}
+
+// downloadPage 下载单个分P，成功后清理临时目录，失败时保留临时文件以便断点续传
+// 启用下载记录时跳过已记录的分P，并在完成后追加记录；指定导出清单时只导出清单
+func downloadPage(task pageTask, config *Config) error {
+	// 只导出清单，不下载，所有入口(单个视频、合集、收藏夹、批量)共用
+	if config.ExportMPD || config.ExportHLS {
+		return exportManifests(task, config)
+	}
+
+	archive, err := openArchive(config)
+	if err != nil {
+		return err
+	}
+	// 片段不是完整的分P，不使用下载记录
+	if config.TimeRange != "" {
+		archive = nil
+	}
+	if archive != nil && archive.Has(task.archiveID(), task.Cid) {
+		fmt.Printf("已在下载记录中，跳过：%s\n", task.fileName())
+		return nil
+	}
+
+	// 分P之间按配置间隔下载
+	config.pageLimiter.Wait()
+
+	tempDir := task.tempDir(config)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	if config.WorkDir != "" {
+		if err := os.MkdirAll(config.WorkDir, 0755); err != nil {
+			return fmt.Errorf("创建工作目录失败: %w", err)
+		}
+	}
+
+	quality, err := processPage(task, tempDir, config)
+	if err != nil {
+		// 目录为空时才会被删除
+		if os.Remove(tempDir) != nil {
+			fmt.Printf("临时文件已保留: %s\n", tempDir)
+		}
+		return err
+	}
+
+	if err := os.RemoveAll(tempDir); err != nil {
+		fmt.Printf("删除临时目录失败: %v\n", err)
+	}
+	// 没有其他任务时一并删除上层临时目录
+	os.Remove(filepath.Dir(tempDir))
+
+	if archive != nil {
+		if err := archive.Add(task.archiveID(), task.Cid, quality); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
+	return nil
+}
+
+// processPage 解析轨道、选择、下载到临时目录并混流到工作目录，返回下载的画质代码
+func processPage(task pageTask, tempDir string, config *Config) (int, error) {
+	// 提取音视频轨道
+	parser := NewParser(config)
+	aidStr := fmt.Sprintf("%d", task.Aid)
+	cidStr := fmt.Sprintf("%d", task.Cid)
+	tracks, err := parser.ExtractTracks("", aidStr, aidStr, cidStr, "", config.UseTVApi, config.UseIntlApi, config.UseAppApi, "")
+	if err != nil {
+		return 0, err
+	}
+
+	// 选择轨道
+	var selectedVideoTrack *Track
+	var selectedAudioTracks []*Track
+
+	// FLV分段中的音频没有单独的流，无法只下载音频
+	if config.AudioOnly && onlyEmbeddedAudio(tracks) {
+		return 0, fmt.Errorf("FLV分段格式不支持仅音频下载，请去掉 --audio-only 下载完整视频")
+	}
+
+	// 处理视频轨道选择
+	if !config.AudioOnly {
+		selectedVideoTrack, err = selectVideoTrack(tracks, config)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// 处理音频轨道选择，分段的FLV文件已包含音频
+	if !config.VideoOnly && !hasEmbeddedAudio(selectedVideoTrack) {
+		selectedAudioTracks, err = selectAudioTracks(parser, tracks, aidStr, cidStr, config)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// 按时间范围下载时只下载覆盖该范围的分段
+	if config.TimeRange != "" {
+		if err := downloadPageRange(task, selectedVideoTrack, selectedAudioTracks, tempDir, config); err != nil {
+			return 0, err
+		}
+		if selectedVideoTrack == nil {
+			return 0, nil
+		}
+		return selectedVideoTrack.Quality, nil
+	}
+
+	// 同时下载视频和音频轨道，连接数受MaxConnections约束
+	var videoPath string
+	var downloads []func() error
+	if selectedVideoTrack != nil {
+		videoPath = filepath.Join(tempDir, fmt.Sprintf("video_%d.mp4", selectedVideoTrack.ID))
+		downloads = append(downloads, func() error {
+			fmt.Printf("正在下载视频：%s\n", selectedVideoTrack.Description)
+			return downloadTrack(selectedVideoTrack, videoPath, config)
+		})
+	}
+
+	audioPaths := make([]string, len(selectedAudioTracks))
+	for i, track := range selectedAudioTracks {
+		track, audioPath := track, filepath.Join(tempDir, tempAudioName(track))
+		audioPaths[i] = audioPath
+		downloads = append(downloads, func() error {
+			fmt.Printf("正在下载音频：%s\n", describeAudio(track))
+			return downloadTrack(track, audioPath, config)
+		})
+	}
+
+	errs := runConcurrent(len(downloads), len(downloads), func(i int) error {
+		return downloads[i]()
+	})
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+
+	fileName := filepath.Join(config.WorkDir, task.fileName())
+
+	// HDR和杜比视界在文件名中标记，便于区分同一视频的SDR版本
+	videoBaseName := fileName
+	if label := dynamicRangeLabel(selectedVideoTrack); label != "" {
+		videoBaseName = fmt.Sprintf("%s [%s]", videoBaseName, label)
+	}
+	multiAudio := len(selectedAudioTracks) > 1
+
+	switch {
+	case selectedVideoTrack != nil && len(selectedAudioTracks) > 0 && config.SkipMux:
+		// 跳过混流，保留原始音视频流
+		fmt.Printf("跳过混流，保留原始音视频流\n")
+		if err := keepRawStream(videoPath, videoBaseName+".video.mp4"); err != nil {
+			return 0, err
+		}
+		for i, track := range selectedAudioTracks {
+			baseName := audioBaseName(fileName, track, multiAudio)
+			if err := keepRawStream(audioPaths[i], baseName+".audio"+rawAudioExtension(audioPaths[i])); err != nil {
+				return 0, err
+			}
+		}
+
+	case selectedVideoTrack != nil && len(selectedAudioTracks) > 0:
+		fmt.Printf("正在混流...\n")
+
+		// 混流输出文件，容器需要能容纳所选音频编码
+		videoOutputPath := videoBaseName + muxContainer(audioPaths, config)
+		if err := muxTracks(selectedVideoTrack, selectedAudioTracks, videoPath, audioPaths, videoOutputPath, config); err != nil {
+			return 0, fmt.Errorf("混流失败，已保留临时文件: %w", err)
+		}
+		fmt.Printf("混流完成: %s\n", videoOutputPath)
+
+		// 需要时单独保存音频文件，其余临时文件随临时目录删除
+		if config.KeepAudio {
+			for i, track := range selectedAudioTracks {
+				audioOutputPath, err := saveAudio(audioPaths[i], audioBaseName(fileName, track, multiAudio), config, true)
+				if err != nil {
+					return 0, fmt.Errorf("保存音频文件失败，已保留临时文件: %w", err)
+				}
+				fmt.Printf("音频已保存: %s\n", audioOutputPath)
+			}
+		}
+
+	case selectedVideoTrack != nil:
+		// 只有视频（或已包含音频的分段文件）
+		// 已包含音频的分段文件或MP4容器直接重命名，其他容器需要转封装
+		var videoOutputPath string
+		if hasEmbeddedAudio(selectedVideoTrack) || config.SkipMux || config.Container == "" || config.Container == ContainerMP4 {
+			videoOutputPath = videoBaseName + ".mp4"
+			err = util.MoveFile(videoPath, videoOutputPath)
+		} else {
+			videoOutputPath = videoBaseName + "." + config.Container
+			err = muxTracks(selectedVideoTrack, nil, videoPath, nil, videoOutputPath, config)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("保存视频文件失败: %w", err)
+		}
+		fmt.Printf("视频已保存: %s\n", videoOutputPath)
+
+	case len(selectedAudioTracks) > 0:
+		// 只有音频，直接重命名为正确的音频格式
+		fmt.Printf("仅下载音频，重命名文件...\n")
+
+		for i, track := range selectedAudioTracks {
+			// 重命名文件，需要时提取或转码为指定格式
+			audioOutputPath, err := saveAudio(audioPaths[i], audioBaseName(fileName, track, multiAudio), config, true)
+			if err != nil {
+				return 0, fmt.Errorf("保存音频文件失败，已保留临时文件: %w", err)
+			}
+			fmt.Printf("音频已保存: %s\n", audioOutputPath)
+		}
+	}
+
+	if selectedVideoTrack == nil {
+		return 0, nil
+	}
+	return selectedVideoTrack.Quality, nil
+}
+
+// keepRawStream 将下载的原始流重命名为最终文件名
+func keepRawStream(srcPath, dstPath string) error {
+	if err := util.MoveFile(srcPath, dstPath); err != nil {
+		return fmt.Errorf("保存原始流失败，已保留临时文件: %w", err)
+	}
+	fmt.Printf("已保存: %s\n", dstPath)
+	return nil
+}
+
+// tempAudioName 生成音频临时文件名，按轨道ID和语言区分
+func tempAudioName(track *Track) string {
+	if track.Language != "" {
+		return fmt.Sprintf("audio_%d_%s.mp4", track.ID, util.CleanFilename(track.Language))
+	}
+	return fmt.Sprintf("audio_%d.mp4", track.ID)
+}
+
+// audioBaseName 生成音频输出文件名（不含扩展名），多语言时加上语言代码
+func audioBaseName(fileName string, track *Track, withLanguage bool) string {
+	if withLanguage && track.Language != "" {
+		return fmt.Sprintf("%s.%s", fileName, track.Language)
+	}
+	return fileName
+}
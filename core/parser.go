@@ -1,9 +1,9 @@
 package core
 
 import (
-	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,12 +15,25 @@ import (
 type Parser struct {
 	HttpClient *HTTPClient // 修复类型名称为HTTPClient
 	Config     *Config
+
+	// Languages 最近一次解析到的可选配音语言，不支持多语言时为空
+	Languages []AudioLanguage
+	// Duration 最近一次解析到的视频时长，接口未返回时为0
+	Duration time.Duration
 }
 
 // NewParser 创建解析器
 func NewParser(config *Config) *Parser {
 	return &Parser{
-		HttpClient: NewHTTPClient(), // 直接创建HTTPClient实例
+		HttpClient: httpClient(config), // 复用本次运行共享的客户端
+		Config:     config,
+	}
+}
+
+// NewParserWithClient 使用指定的HTTP客户端创建解析器
+func NewParserWithClient(config *Config, client *HTTPClient) *Parser {
+	return &Parser{
+		HttpClient: client,
 		Config:     config,
 	}
 }
@@ -39,7 +52,7 @@ func (p *Parser) ExtractTracks(encoding, aidOri, aid, cid, epId string, tvApi, i
 	} else if appApi {
 		playJson, err = p.getAppPlayJson(aid, cid, epId, qn, false, encoding, "")
 	} else {
-		playJson, err = p.getPlayJson(encoding, aidOri, aid, cid, epId, tvApi, false, appApi, qn)
+		playJson, err = p.getPlayJson(encoding, aidOri, aid, cid, epId, tvApi, false, appApi, qn, "")
 	}
 
 	if err != nil {
@@ -55,27 +68,53 @@ func (p *Parser) ExtractTracks(encoding, aidOri, aid, cid, epId string, tvApi, i
 	return tracks, nil
 }
 
-// getPlayJson 获取播放数据JSON
-func (p *Parser) getPlayJson(encoding, aidOri, aid, cid, epId string, tvApi, intlApi, appApi bool, qn string) (string, error) {
+// ExtractLanguageTracks 提取指定配音语言的音频轨道
+// 多语言视频的每种配音需要单独请求playurl，仅Web接口支持
+func (p *Parser) ExtractLanguageTracks(aidOri, aid, cid, epId, lang string) ([]*Track, error) {
+	playJson, err := p.getPlayJson("", aidOri, aid, cid, epId, false, false, false, "0", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks, err := p.parsePlayData(playJson, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var audioTracks []*Track
+	for _, track := range tracks {
+		if track.FrameType == "audio" {
+			if track.Language == "" {
+				track.Language = lang
+			}
+			audioTracks = append(audioTracks, track)
+		}
+	}
+	return audioTracks, nil
+}
+
+// getPlayJson 获取播放数据JSON，lang不为空时请求对应语言的配音
+func (p *Parser) getPlayJson(encoding, aidOri, aid, cid, epId string, tvApi, intlApi, appApi bool, qn, lang string) (string, error) {
 	isCheese := strings.HasPrefix(aidOri, "cheese:")
 	isBangumi := isCheese || strings.HasPrefix(aidOri, "ep:")
 
 	var prefix string
 	if tvApi {
 		if isBangumi {
-			prefix = fmt.Sprintf("https://%s/pgc/player/api/playurltv?", p.Config.TvHost)
+			prefix = p.HttpClient.apiURL(p.Config.TvHost, "/pgc/player/api/playurltv?")
 		} else {
-			prefix = fmt.Sprintf("https://%s/x/tv/playurl?", p.Config.TvHost)
+			prefix = p.HttpClient.apiURL(p.Config.TvHost, "/x/tv/playurl?")
 		}
 	} else {
 		if isBangumi {
-			prefix = fmt.Sprintf("https://%s/pgc/player/web/v2/playurl?", p.Config.Host)
+			prefix = p.HttpClient.apiURL(p.Config.Host, "/pgc/player/web/v2/playurl?")
 		} else {
-			prefix = "https://api.bilibili.com/x/player/wbi/playurl?"
+			prefix = p.HttpClient.apiURL("api.bilibili.com", "/x/player/wbi/playurl?")
 		}
 	}
 
 	var api string
+	var wbiParams map[string]string
 	if tvApi {
 		params := make(map[string]string)
 		if p.Config.AccessToken != "" {
@@ -126,18 +165,17 @@ func (p *Parser) getPlayJson(encoding, aidOri, aid, cid, epId string, tvApi, int
 		if p.Config.Cookie == "" {
 			params["try_look"] = "1"
 		}
-		params["wts"] = strconv.FormatInt(time.Now().Unix(), 10)
+		if lang != "" {
+			params["cur_language"] = lang
+		}
 
 		if isBangumi {
+			params["wts"] = strconv.FormatInt(time.Now().Unix(), 10)
 			api = fmt.Sprintf("%s%s", prefix, buildQueryStringHTTP(params))
 		} else {
-			// WBI签名
-			wbiKey, err := GetWBIKey(p.HttpClient)
-			if err != nil {
-				return "", fmt.Errorf("获取WBI密钥失败: %w", err)
-			}
-			queryString := buildQueryStringHTTP(params)
-			api = fmt.Sprintf("%s%s&w_rid=%s", prefix, queryString, util.WBISign(queryString, wbiKey))
+			// WBI签名在发送时进行
+			api = prefix
+			wbiParams = params
 		}
 	}
 
@@ -147,7 +185,13 @@ func (p *Parser) getPlayJson(encoding, aidOri, aid, cid, epId string, tvApi, int
 	}
 
 	// 发送请求
-	resp, err := p.HttpClient.GetWebSource(api, p.Config.UserAgent)
+	var resp string
+	var err error
+	if wbiParams != nil {
+		resp, err = p.HttpClient.GetWBISource(api, wbiParams, p.Config.UserAgent)
+	} else {
+		resp, err = p.HttpClient.GetWebSource(api, p.Config.UserAgent)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -155,7 +199,7 @@ func (p *Parser) getPlayJson(encoding, aidOri, aid, cid, epId string, tvApi, int
 	// 检查是否需要从网页源码解析
 	if strings.Contains(resp, "大会员专享限制") {
 		// 从网页源码尝试解析
-		webUrl := fmt.Sprintf("https://www.bilibili.com/bangumi/play/ep%s", epId)
+		webUrl := p.HttpClient.apiURL("www.bilibili.com", "/bangumi/play/ep"+epId)
 		webSource, err := p.HttpClient.GetWebSource(webUrl, p.Config.UserAgent)
 		if err != nil {
 			return "", err
@@ -169,6 +213,9 @@ func (p *Parser) getPlayJson(encoding, aidOri, aid, cid, epId string, tvApi, int
 		}
 	}
 
+	if err := checkAPIResponse(api, resp); err != nil {
+		return "", err
+	}
 	return resp, nil
 }
 
@@ -177,9 +224,9 @@ func (p *Parser) getIntlPlayJson(aid, cid, epId, qn, code string) (string, error
 	isBiliPlus := p.Config.Host != "api.bilibili.com"
 	var apiPrefix string
 	if isBiliPlus {
-		apiPrefix = fmt.Sprintf("https://%s/intl/gateway/v2/ogv/playurl?", p.Config.Host)
+		apiPrefix = p.HttpClient.apiURL(p.Config.Host, "/intl/gateway/v2/ogv/playurl?")
 	} else {
-		apiPrefix = "https://api.biliintl.com/intl/gateway/v2/ogv/playurl?"
+		apiPrefix = p.HttpClient.apiURL("api.biliintl.com", "/intl/gateway/v2/ogv/playurl?")
 	}
 
 	params := make(map[string]string)
@@ -220,6 +267,9 @@ func (p *Parser) getIntlPlayJson(aid, cid, epId, qn, code string) (string, error
 		return "", err
 	}
 
+	if err := checkAPIResponse(api, resp); err != nil {
+		return "", err
+	}
 	return resp, nil
 }
 
@@ -230,34 +280,35 @@ func (p *Parser) getAppPlayJson(aid, cid, epId, qn string, bangumi bool, encodin
 }
 
 // parsePlayData 解析播放数据
+// 接口返回的结构缺少必需字段或字段类型不符时返回错误
 func (p *Parser) parsePlayData(playJson string, encoding string) ([]*Track, error) {
-	// 解析JSON
-	var data map[string]interface{}
-	if err := json.Unmarshal([]byte(playJson), &data); err != nil {
+	if err := checkAPIResponse("", playJson); err != nil {
+		return nil, err
+	}
+	resp, err := decodePlayURL(playJson)
+	if err != nil {
 		return nil, err
 	}
-
-	var tracks []*Track
 
 	// 检查是否是国际版接口 (stream_list)
-	if p.hasStreamList(data) {
-		intlTracks, err := p.parseIntlPlayData(data)
-		if err != nil {
-			return nil, err
-		}
-		tracks = append(tracks, intlTracks...)
-		return tracks, nil
+	if intl := resp.intl(); intl != nil {
+		return p.parseIntlPlayData(intl)
 	}
 
-	// 获取根节点
-	root := p.getRootNode(data)
+	root := resp.root()
 	if root == nil {
 		return nil, fmt.Errorf("无法解析播放数据根节点")
 	}
 
+	// 解析多语言配音列表
+	p.Languages = p.parseLanguages(root)
+	p.Duration = root.duration()
+
+	var tracks []*Track
+
 	// 解析DASH格式
-	if dash, ok := root["dash"].(map[string]interface{}); ok {
-		dashTracks, err := p.parseDashData(dash)
+	if root.Dash != nil {
+		dashTracks, err := p.parseDashData(root.Dash)
 		if err != nil {
 			return nil, err
 		}
@@ -265,8 +316,8 @@ func (p *Parser) parsePlayData(playJson string, encoding string) ([]*Track, erro
 	}
 
 	// 解析FLV格式 (durl)
-	if durl, ok := root["durl"].([]interface{}); ok {
-		flvTracks, err := p.parseFlvData(root, durl)
+	if root.Durl != nil {
+		flvTracks, err := p.parseFlvData(root)
 		if err != nil {
 			return nil, err
 		}
@@ -276,267 +327,226 @@ func (p *Parser) parsePlayData(playJson string, encoding string) ([]*Track, erro
 	return tracks, nil
 }
 
-// hasStreamList 检查是否包含stream_list (国际版接口)
-func (p *Parser) hasStreamList(data map[string]interface{}) bool {
-	if dataObj, ok := data["data"].(map[string]interface{}); ok {
-		if _, ok := dataObj["video_info"]; ok {
-			if videoInfo, ok := dataObj["video_info"].(map[string]interface{}); ok {
-				if _, ok := videoInfo["stream_list"]; ok {
-					return true
-				}
-			}
-		}
-	}
-	return false
-}
-
 // parseIntlPlayData 解析国际版播放数据
-func (p *Parser) parseIntlPlayData(data map[string]interface{}) ([]*Track, error) {
+func (p *Parser) parseIntlPlayData(videoInfo *playURLData) ([]*Track, error) {
 	var tracks []*Track
 
-	dataObj := data["data"].(map[string]interface{})
-	videoInfo := dataObj["video_info"].(map[string]interface{})
-	_ = p.getIntValue(videoInfo, "timelength") / 1000 // duration用于国际版接口
-
-	// 处理视频流
-	if streamList, ok := videoInfo["stream_list"].([]interface{}); ok {
-		for _, stream := range streamList {
-			if streamMap, ok := stream.(map[string]interface{}); ok {
-				if dashVideo, ok := streamMap["dash_video"].(map[string]interface{}); ok {
-					if baseURL, ok := dashVideo["base_url"].(string); ok && baseURL != "" {
-						track := &Track{
-							ID:          p.getIntValue(streamMap["stream_info"], "quality"),
-							Description: getQualityDesc(p.getIntValue(streamMap["stream_info"], "quality")),
-							URL:         baseURL,
-							Bandwidth:   p.getIntValue(dashVideo, "bandwidth") / 1000,
-							FrameType:   "video",
-							Codec:       p.getVideoCodec(p.getIntValue(dashVideo, "codecid")),
-							Size:        int64(p.getIntValue(dashVideo, "size")),
-						}
-						tracks = append(tracks, track)
-					}
-				}
-			}
+	// 处理视频流，没有权限的清晰度不返回dash_video
+	for _, stream := range videoInfo.StreamList {
+		video := stream.DashVideo
+		if video == nil || video.BaseURL == "" {
+			continue
 		}
+		quality := stream.StreamInfo.Quality
+		if quality == 0 {
+			return nil, fmt.Errorf("国际版视频流缺少quality字段")
+		}
+		url, backupURLs := video.urls()
+		tracks = append(tracks, &Track{
+			ID:          quality,
+			Quality:     quality,
+			Description: QualityDesc(quality),
+			URL:         url,
+			BackupURLs:  backupURLs,
+			Bandwidth:   video.Bandwidth / 1000,
+			FrameType:   "video",
+			Codec:       p.getVideoCodec(video.Codecid),
+			Size:        video.Size,
+		})
 	}
 
 	// 处理音频流
-	if dashAudio, ok := videoInfo["dash_audio"].([]interface{}); ok {
-		for _, audio := range dashAudio {
-			if audioMap, ok := audio.(map[string]interface{}); ok {
-				track := &Track{
-					ID:          p.getIntValue(audioMap, "id"),
-					Description: fmt.Sprintf("%d", p.getIntValue(audioMap, "id")),
-					URL:         p.getStringValue(audioMap, "base_url"),
-					Bandwidth:   p.getIntValue(audioMap, "bandwidth") / 1000,
-					FrameType:   "audio",
-					Codec:       "M4A",
-				}
-				tracks = append(tracks, track)
-			}
+	for _, audio := range videoInfo.DashAudio {
+		if audio.ID == 0 || audio.BaseURL == "" {
+			return nil, fmt.Errorf("国际版音频流缺少id或base_url字段")
 		}
+		url, backupURLs := audio.urls()
+		tracks = append(tracks, &Track{
+			ID:          audio.ID,
+			Description: getAudioDesc(audio.ID),
+			URL:         url,
+			BackupURLs:  backupURLs,
+			Bandwidth:   audio.Bandwidth / 1000,
+			FrameType:   "audio",
+			Codec:       "M4A",
+			Size:        audio.Size,
+		})
 	}
 
 	return tracks, nil
 }
 
-// getRootNode 获取根节点
-func (p *Parser) getRootNode(data map[string]interface{}) map[string]interface{} {
-	// 检查result节点
-	if result, ok := data["result"].(map[string]interface{}); ok {
-		// v2接口有video_info节点
-		if videoInfo, ok := result["video_info"].(map[string]interface{}); ok {
-			return videoInfo
-		}
-		return result
-	}
-
-	// 检查data节点
-	if dataObj, ok := data["data"].(map[string]interface{}); ok {
-		return dataObj
-	}
-
-	return data
-}
-
 // parseDashData 解析DASH数据
-func (p *Parser) parseDashData(dash map[string]interface{}) ([]*Track, error) {
+func (p *Parser) parseDashData(dash *dashInfo) ([]*Track, error) {
 	var tracks []*Track
 
 	// 处理视频流
-	if videoData, ok := dash["video"].([]interface{}); ok {
-		for _, v := range videoData {
-			if video, ok := v.(map[string]interface{}); ok {
-				// 尝试获取最佳URL，优先使用backup_url
-				baseURL := p.getStringValue(video, "base_url")
-				if backupUrls, ok := video["backup_url"].([]interface{}); ok && len(backupUrls) > 0 {
-					// 使用第一个备用URL
-					if backupURL, ok := backupUrls[0].(string); ok && backupURL != "" {
-						baseURL = backupURL
-					}
-				}
-
-				track := &Track{
-					ID:          p.getIntValue(video, "id"),
-					Description: getQualityDesc(p.getIntValue(video, "id")),
-					URL:         baseURL,
-					Bandwidth:   p.getIntValue(video, "bandwidth") / 1000,
-					FrameType:   "video",
-					Codec:       p.getVideoCodec(p.getIntValue(video, "codecid")),
-					Size:        int64(p.getIntValue(video, "size")),
-					Width:       p.getIntValue(video, "width"),
-					Height:      p.getIntValue(video, "height"),
-					FPS:         p.getIntValue(video, "frame_rate"),
-				}
-				tracks = append(tracks, track)
-			}
+	for i := range dash.Video {
+		video := &dash.Video[i]
+		if err := video.validate("DASH视频", true); err != nil {
+			return nil, err
 		}
+		track := p.dashTrack(video, "video")
+		track.Quality = video.ID
+		track.Description = QualityDesc(video.ID)
+		track.Codec = p.getVideoCodec(video.Codecid)
+		track.Width = video.Width
+		track.Height = video.Height
+		track.FPS = video.fps()
+		track.FrameRate = video.FrameRate
+		track.Sar = video.Sar
+		tracks = append(tracks, track)
 	}
 
 	// 处理音频流
-	if audioData, ok := dash["audio"].([]interface{}); ok {
-		for _, a := range audioData {
-			if audio, ok := a.(map[string]interface{}); ok {
-				codecs := p.getStringValue(audio, "codecs")
-				// 转换编码格式
-				switch codecs {
-				case "mp4a.40.2", "mp4a.40.5":
-					codecs = "M4A"
-				case "ec-3":
-					codecs = "E-AC-3"
-				case "fLaC":
-					codecs = "FLAC"
-				}
-
-				// 尝试获取最佳URL，优先使用backup_url
-				baseURL := p.getStringValue(audio, "base_url")
-				if backupUrls, ok := audio["backup_url"].([]interface{}); ok && len(backupUrls) > 0 {
-					// 使用第一个备用URL
-					if backupURL, ok := backupUrls[0].(string); ok && backupURL != "" {
-						baseURL = backupURL
-					}
-				}
-
-				track := &Track{
-					ID:          p.getIntValue(audio, "id"),
-					Description: p.getStringValue(audio, "id"),
-					URL:         baseURL,
-					Bandwidth:   p.getIntValue(audio, "bandwidth") / 1000,
-					FrameType:   "audio",
-					Codec:       codecs,
-				}
-				tracks = append(tracks, track)
-			}
+	for i := range dash.Audio {
+		audio := &dash.Audio[i]
+		if err := audio.validate("DASH音频", false); err != nil {
+			return nil, err
+		}
+		track := p.dashTrack(audio, "audio")
+		// 转换编码格式
+		switch audio.Codecs {
+		case "mp4a.40.2", "mp4a.40.5":
+			track.Codec = "M4A"
+		case "ec-3":
+			track.Codec = "E-AC-3"
+		case "fLaC":
+			track.Codec = "FLAC"
+		default:
+			track.Codec = audio.Codecs
 		}
+		track.Language = audio.Lang
+		tracks = append(tracks, track)
 	}
 
 	// 处理杜比音频
-	if dolby, ok := dash["dolby"].(map[string]interface{}); ok {
-		if dolbyAudio, ok := dolby["audio"].([]interface{}); ok {
-			for _, a := range dolbyAudio {
-				if audio, ok := a.(map[string]interface{}); ok {
-					// 尝试获取最佳URL，优先使用backup_url
-					baseURL := p.getStringValue(audio, "base_url")
-					if backupUrls, ok := audio["backup_url"].([]interface{}); ok && len(backupUrls) > 0 {
-						// 使用第一个备用URL
-						if backupURL, ok := backupUrls[0].(string); ok && backupURL != "" {
-							baseURL = backupURL
-						}
-					}
-
-					track := &Track{
-						ID:          p.getIntValue(audio, "id"),
-						Description: p.getStringValue(audio, "id"),
-						URL:         baseURL,
-						Bandwidth:   p.getIntValue(audio, "bandwidth") / 1000,
-						FrameType:   "audio",
-						Codec:       "E-AC-3",
-					}
-					tracks = append(tracks, track)
-				}
+	if dash.Dolby != nil {
+		for i := range dash.Dolby.Audio {
+			audio := &dash.Dolby.Audio[i]
+			if err := audio.validate("杜比音频", false); err != nil {
+				return nil, err
 			}
+			track := p.dashTrack(audio, "audio")
+			track.Codec = "E-AC-3"
+			tracks = append(tracks, track)
 		}
 	}
 
 	// 处理Hi-Res无损音频
-	if flac, ok := dash["flac"].(map[string]interface{}); ok {
-		if flacAudio, ok := flac["audio"].(map[string]interface{}); ok {
-			if flacAudio != nil {
-				track := &Track{
-					ID:          p.getIntValue(flacAudio, "id"),
-					Description: p.getStringValue(flacAudio, "id"),
-					URL:         p.getStringValue(flacAudio, "base_url"),
-					Bandwidth:   p.getIntValue(flacAudio, "bandwidth") / 1000,
-					FrameType:   "audio",
-					Codec:       "FLAC",
-				}
-				tracks = append(tracks, track)
-			}
+	if dash.Flac != nil && dash.Flac.Audio != nil {
+		audio := dash.Flac.Audio
+		if err := audio.validate("Hi-Res音频", false); err != nil {
+			return nil, err
 		}
+		track := p.dashTrack(audio, "audio")
+		track.Codec = "FLAC"
+		tracks = append(tracks, track)
 	}
 
 	return tracks, nil
 }
 
-// parseFlvData 解析FLV数据
-func (p *Parser) parseFlvData(root map[string]interface{}, durl []interface{}) ([]*Track, error) {
-	var tracks []*Track
-
-	quality := p.getIntValue(root, "quality")
-	videoCodecid := p.getStringValue(root, "video_codecid")
-
-	var totalSize int64
-	var totalLength int64
-
-	// 计算总大小和时长
-	for _, node := range durl {
-		if clip, ok := node.(map[string]interface{}); ok {
-			totalSize += p.getInt64Value(clip, "size")
-			totalLength += p.getInt64Value(clip, "length")
+// dashTrack 创建DASH轨道的公共部分
+func (p *Parser) dashTrack(stream *dashStream, frameType string) *Track {
+	url, backupURLs := stream.urls()
+	track := &Track{
+		ID:           stream.ID,
+		URL:          url,
+		BackupURLs:   backupURLs,
+		Bandwidth:    stream.Bandwidth / 1000,
+		FrameType:    frameType,
+		Size:         stream.Size,
+		Codecid:      stream.Codecid,
+		Codecs:       stream.Codecs,
+		MimeType:     stream.MimeType,
+		StartWithSap: stream.StartWithSap,
+	}
+	if frameType == "audio" {
+		track.Description = getAudioDesc(stream.ID)
+	}
+	if stream.SegmentBase.IndexRange != "" {
+		track.SegmentBase = &SegmentBase{
+			Initialization: stream.SegmentBase.Initialization,
+			IndexRange:     stream.SegmentBase.IndexRange,
 		}
 	}
+	return track
+}
 
-	// 创建FLV轨道
-	track := &Track{
-		ID:          quality,
-		Description: getQualityDesc(quality),
-		FrameType:   "video",
-		Codec:       p.getVideoCodecString(videoCodecid),
-		Size:        totalSize,
+// parseLanguages 解析多语言配音列表
+// 支持多语言的视频在根节点返回 language.items，每项包含 lang 和 title
+func (p *Parser) parseLanguages(root *playURLData) []AudioLanguage {
+	if root.Language == nil {
+		return nil
 	}
 
-	tracks = append(tracks, track)
-	return tracks, nil
-}
-
-// getIntValue 安全获取int值
-func (p *Parser) getIntValue(obj interface{}, key string) int {
-	if m, ok := obj.(map[string]interface{}); ok {
-		if val, ok := m[key].(float64); ok {
-			return int(val)
+	var languages []AudioLanguage
+	for _, item := range root.Language.Items {
+		if item.Lang == "" {
+			continue
 		}
+		languages = append(languages, item)
 	}
-	return 0
+	return languages
 }
 
-// getInt64Value 安全获取int64值
-func (p *Parser) getInt64Value(obj interface{}, key string) int64 {
-	if m, ok := obj.(map[string]interface{}); ok {
-		if val, ok := m[key].(float64); ok {
-			return int64(val)
+// parseFlvData 解析FLV数据
+// 旧视频和TV接口可能返回分段的FLV/MP4文件（durl），每段有独立的地址，
+// 所有分段按顺序组成一个包含音视频的轨道
+func (p *Parser) parseFlvData(root *playURLData) ([]*Track, error) {
+	var totalSize int64
+	var segments []Segment
+
+	for i, clip := range root.Durl {
+		if clip.URL == "" {
+			return nil, fmt.Errorf("第%d个分段缺少下载地址", i+1)
 		}
-	}
-	return 0
-}
 
-// getStringValue 安全获取string值
-func (p *Parser) getStringValue(obj interface{}, key string) string {
-	if m, ok := obj.(map[string]interface{}); ok {
-		if val, ok := m[key].(string); ok {
-			return val
+		segment := Segment{
+			Index:  clip.Order,
+			URL:    clip.URL,
+			Size:   clip.Size,
+			Length: clip.Length,
+		}
+		if segment.Index == 0 {
+			segment.Index = i + 1
+		}
+		for _, backupURL := range clip.BackupURL {
+			if backupURL != "" {
+				segment.BackupURLs = append(segment.BackupURLs, backupURL)
+			}
 		}
+
+		totalSize += segment.Size
+		segments = append(segments, segment)
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("durl中没有可用的分段")
+	}
+	if root.Quality == 0 {
+		return nil, fmt.Errorf("FLV播放数据缺少quality字段")
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Index < segments[j].Index
+	})
+
+	// 创建分段轨道
+	track := &Track{
+		ID:          root.Quality,
+		Quality:     root.Quality,
+		Description: QualityDesc(root.Quality),
+		URL:         segments[0].URL,
+		FrameType:   "video",
+		Format:      root.Format,
+		Codec:       p.getVideoCodec(root.VideoCodecid),
+		Size:        totalSize,
+		Segments:    segments,
 	}
-	return ""
+
+	return []*Track{track}, nil
 }
 
 // getVideoCodec 获取视频编码
@@ -553,93 +563,26 @@ func (p *Parser) getVideoCodec(code int) string {
 	}
 }
 
-// getVideoCodecString 获取视频编码字符串
-func (p *Parser) getVideoCodecString(code string) string {
-	switch code {
-	case "13":
-		return "AV1"
-	case "12":
-		return "HEVC"
-	case "7":
-		return "AVC"
-	default:
-		return "UNKNOWN"
-	}
-}
-
-// convertToTrack 转换为轨道
-func (p *Parser) convertToTrack(data map[string]interface{}, trackType, encoding string) *Track {
-	// 提取基础信息
-	id, _ := data["id"].(float64)
-	baseUrl, _ := data["baseUrl"].(string)
-	// backupUrl, _ := data["backupUrl"].([]interface{}) // 删除未使用的变量
-	mimeType, _ := data["mimeType"].(string)
-	codecs, _ := data["codecs"].(string)
-	width, _ := data["width"].(float64)
-	height, _ := data["height"].(float64)
-	frameRate, _ := data["frameRate"].(string)
-	// sar, _ := data["sar"].(string)  // 删除未使用的变量
-	codecid, _ := data["codecid"].(float64)
-	// size, _ := data["size"].(float64)  // 删除未使用的变量
-	bandwidth, _ := data["bandwidth"].(float64)
-	// avgBitrate, _ := data["avgBitrate"].(float64)  // 删除未使用的变量
-	// maxBitrate, _ := data["maxBitrate"].(float64)  // 删除未使用的变量
-	// profile, _ := data["profile"].(string)  // 删除未使用的变量
-	// duration, _ := data["duration"].(float64)  // 删除未使用的变量
-	// fnval, _ := data["fnval"].(float64)  // 删除未使用的变量
-	// fnver, _ := data["fnver"].(float64)
-	// fourk, _ := data["fourk"].(float64)
-	// dashId, _ := data["dashId"].(string)
-	// rotation, _ := data["rotation"].(float64)
-	frameRateF, _ := strconv.ParseFloat(frameRate, 64)
-
-	// 创建轨道
-	track := &Track{
-		ID:          int(id),
-		Codecid:     int(codecid),
-		Quality:     int(id), // 使用id作为quality
-		Description: fmt.Sprintf("%dx%d", int(width), int(height)),
-		URL:         baseUrl,
-		Format:      mimeType,
-		Codec:       codecs,
-		Width:       int(width),
-		Height:      int(height),
-		Bandwidth:   int(bandwidth),
-		FPS:         int(frameRateF),
-		FrameType:   trackType,
-	}
-
-	return track
-}
+// 特殊音质的音频ID
+const (
+	audioIDDolby = 30250 // 杜比全景声
+	audioIDHiRes = 30251 // Hi-Res无损
+)
 
-// getQualityDesc 获取画质描述
-func getQualityDesc(qn int) string {
-	switch qn {
-	case 120:
-		return "4K 超高清"
-	case 116:
-		return "1080P 高码率"
-	case 112:
-		return "1080P 高码率"
-	case 80:
-		return "1080P 高清"
-	case 74:
-		return "720P 60帧"
-	case 72:
-		return "720P 高清"
-	case 64:
-		return "480P 清晰"
-	case 32:
-		return "360P 流畅"
-	case 16:
-		return "240P 极速"
+// getAudioDesc 获取音质描述
+func getAudioDesc(id int) string {
+	switch id {
+	case 30216:
+		return "64K"
+	case 30232:
+		return "132K"
+	case 30280:
+		return "192K"
+	case audioIDDolby:
+		return "杜比全景声 Dolby Atmos"
+	case audioIDHiRes:
+		return "Hi-Res无损"
 	default:
-		return fmt.Sprintf("未知画质(%d)", qn)
+		return fmt.Sprintf("未知音质(%d)", id)
 	}
 }
-
-// getWBIKey 获取WBI密钥
-func (p *Parser) getWBIKey() (string, error) {
-	// WBI密钥获取逻辑暂未实现
-	return "", nil
-}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// playURLResponse playurl接口响应，普通视频在data节点，番剧在result节点
+type playURLResponse struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Data    *playURLData `json:"data"`
+	Result  *playURLData `json:"result"`
+}
+
+// playURLData 播放数据，DASH和FLV(durl)格式共用
+type playURLData struct {
+	Quality       int            `json:"quality"`
+	Format        string         `json:"format"`
+	Timelength    int64          `json:"timelength"`
+	VideoCodecid  int            `json:"video_codecid"`
+	AcceptQuality []int          `json:"accept_quality"`
+	Dash          *dashInfo      `json:"dash"`
+	Durl          []durlSegment  `json:"durl"`
+	Language      *playLanguages `json:"language"`
+
+	// 番剧v2接口和国际版接口的数据在video_info节点
+	VideoInfo *playURLData `json:"video_info"`
+
+	// 国际版接口
+	StreamList []intlStream `json:"stream_list"`
+	DashAudio  []dashStream `json:"dash_audio"`
+}
+
+// playLanguages 多语言配音列表
+type playLanguages struct {
+	Items []AudioLanguage `json:"items"`
+}
+
+// dashInfo DASH格式的音视频流
+type dashInfo struct {
+	Duration      int          `json:"duration"`
+	MinBufferTime float64      `json:"min_buffer_time"`
+	Video         []dashStream `json:"video"`
+	Audio         []dashStream `json:"audio"`
+	Dolby         *struct {
+		Type  int          `json:"type"`
+		Audio []dashStream `json:"audio"`
+	} `json:"dolby"`
+	Flac *struct {
+		Display bool        `json:"display"`
+		Audio   *dashStream `json:"audio"`
+	} `json:"flac"`
+}
+
+// dashStream DASH中的一路视频或音频流
+type dashStream struct {
+	ID           int         `json:"id"`
+	BaseURL      string      `json:"base_url"`
+	BackupURL    []string    `json:"backup_url"`
+	Bandwidth    int         `json:"bandwidth"`
+	MimeType     string      `json:"mime_type"`
+	Codecs       string      `json:"codecs"`
+	Codecid      int         `json:"codecid"`
+	Width        int         `json:"width"`
+	Height       int         `json:"height"`
+	FrameRate    string      `json:"frame_rate"`
+	Sar          string      `json:"sar"`
+	StartWithSap int         `json:"start_with_sap"`
+	SegmentBase  segmentBase `json:"segment_base"`
+	Size         int64       `json:"size"`
+	Lang         string      `json:"lang"`
+}
+
+// segmentBase 初始化段和sidx索引在文件中的字节范围，如 "0-1000"
+type segmentBase struct {
+	Initialization string `json:"initialization"`
+	IndexRange     string `json:"index_range"`
+}
+
+// durlSegment FLV/MP4分段
+type durlSegment struct {
+	Order     int      `json:"order"`
+	Length    int64    `json:"length"`
+	Size      int64    `json:"size"`
+	URL       string   `json:"url"`
+	BackupURL []string `json:"backup_url"`
+}
+
+// intlStream 国际版接口的视频流
+type intlStream struct {
+	StreamInfo struct {
+		Quality        int    `json:"quality"`
+		NewDescription string `json:"new_description"`
+	} `json:"stream_info"`
+	DashVideo *dashStream `json:"dash_video"`
+}
+
+// decodePlayURL 解析playurl接口响应
+func decodePlayURL(playJson string) (*playURLResponse, error) {
+	var resp playURLResponse
+	if err := json.Unmarshal([]byte(playJson), &resp); err != nil {
+		return nil, fmt.Errorf("解析播放数据失败: %w", err)
+	}
+	return &resp, nil
+}
+
+// root 返回包含播放数据的节点
+func (r *playURLResponse) root() *playURLData {
+	if r.Result != nil {
+		if r.Result.VideoInfo != nil {
+			return r.Result.VideoInfo
+		}
+		return r.Result
+	}
+	return r.Data
+}
+
+// intl 返回国际版接口的播放数据，不是国际版响应时返回nil
+func (r *playURLResponse) intl() *playURLData {
+	if r.Data != nil && r.Data.VideoInfo != nil && r.Data.VideoInfo.StreamList != nil {
+		return r.Data.VideoInfo
+	}
+	return nil
+}
+
+// duration 视频时长，优先使用毫秒精度的timelength
+func (d *playURLData) duration() time.Duration {
+	if d.Timelength > 0 {
+		return time.Duration(d.Timelength) * time.Millisecond
+	}
+	if d.Dash != nil {
+		return time.Duration(d.Dash.Duration) * time.Second
+	}
+	return 0
+}
+
+// validate 检查必需字段，避免缺失的字段被当作零值继续下载
+func (s *dashStream) validate(kind string, video bool) error {
+	var missing string
+	switch {
+	case s.ID == 0:
+		missing = "id"
+	case s.BaseURL == "" && len(s.BackupURL) == 0:
+		missing = "base_url"
+	case s.Bandwidth == 0:
+		missing = "bandwidth"
+	case s.Codecs == "":
+		missing = "codecs"
+	case video && (s.Width == 0 || s.Height == 0):
+		missing = "width/height"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s流(id=%d)缺少%s字段", kind, s.ID, missing)
+}
+
+// urls 返回优先使用的地址和备用地址，优先使用backup_url
+func (s *dashStream) urls() (string, []string) {
+	urls := append(append([]string{}, s.BackupURL...), s.BaseURL)
+	var result []string
+	for _, u := range urls {
+		if u != "" {
+			result = append(result, u)
+		}
+	}
+	if len(result) == 0 {
+		return "", nil
+	}
+	return result[0], result[1:]
+}
+
+// fps 帧率，接口返回的frame_rate为字符串，如"29.412"
+func (s *dashStream) fps() int {
+	frameRate, _ := strconv.ParseFloat(s.FrameRate, 64)
+	return int(frameRate + 0.5)
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QualityInfo 画质信息
+type QualityInfo struct {
+	ID          int    `json:"id"`          // 画质代码(qn)
+	Name        string `json:"name"`        // 中文名称，如 1080P 高清
+	EnglishName string `json:"englishName"` // 英文名称，如 1080P
+	Width       int    `json:"width"`       // 标称宽度
+	Height      int    `json:"height"`      // 标称高度
+	NeedVIP     bool   `json:"needVip"`     // 是否需要大会员
+}
+
+// Qualities 画质表，按画质从高到低排列
+// 解析器、轨道选择和命令行参数都以此表为准
+var Qualities = []QualityInfo{
+	{ID: 127, Name: "8K 超高清", EnglishName: "8K", Width: 7680, Height: 4320, NeedVIP: true},
+	{ID: 126, Name: "杜比视界", EnglishName: "Dolby Vision", Width: 3840, Height: 2160, NeedVIP: true},
+	{ID: 125, Name: "HDR 真彩", EnglishName: "HDR", Width: 3840, Height: 2160, NeedVIP: true},
+	{ID: 120, Name: "4K 超清", EnglishName: "4K", Width: 3840, Height: 2160, NeedVIP: true},
+	{ID: 116, Name: "1080P 高帧率", EnglishName: "1080P60", Width: 1920, Height: 1080, NeedVIP: true},
+	{ID: 112, Name: "1080P 高码率", EnglishName: "1080P+", Width: 1920, Height: 1080, NeedVIP: true},
+	{ID: 100, Name: "智能修复", EnglishName: "AI Restored", Width: 1920, Height: 1080, NeedVIP: true},
+	{ID: 80, Name: "1080P 高清", EnglishName: "1080P", Width: 1920, Height: 1080},
+	{ID: 74, Name: "720P 高帧率", EnglishName: "720P60", Width: 1280, Height: 720},
+	{ID: 64, Name: "720P 高清", EnglishName: "720P", Width: 1280, Height: 720},
+	{ID: 32, Name: "480P 清晰", EnglishName: "480P", Width: 852, Height: 480},
+	{ID: 16, Name: "360P 流畅", EnglishName: "360P", Width: 640, Height: 360},
+	{ID: 6, Name: "240P 极速", EnglishName: "240P", Width: 426, Height: 240},
+	{ID: 5, Name: "144P 流畅", EnglishName: "144P", Width: 256, Height: 144},
+}
+
+// EncodingMap 编码映射表
+var EncodingMap = map[string]string{
+	"hevc": "HEVC",
+	"av1":  "AV1",
+	"avc":  "AVC",
+}
+
+// LookupQuality 根据画质代码查找画质信息
+func LookupQuality(qn int) (QualityInfo, bool) {
+	for _, q := range Qualities {
+		if q.ID == qn {
+			return q, true
+		}
+	}
+	return QualityInfo{}, false
+}
+
+// ParseQuality 解析画质，支持画质代码("80")、中文名称("1080P 高清")和英文名称("1080P")
+// 名称比较忽略大小写和空格
+func ParseQuality(s string) (QualityInfo, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return QualityInfo{}, false
+	}
+
+	if qn, err := strconv.Atoi(s); err == nil {
+		return LookupQuality(qn)
+	}
+
+	key := normalizeQualityName(s)
+	for _, q := range Qualities {
+		if normalizeQualityName(q.Name) == key || normalizeQualityName(q.EnglishName) == key {
+			return q, true
+		}
+	}
+	return QualityInfo{}, false
+}
+
+// ParseQualityPriority 解析画质优先级列表，返回画质代码和无法识别的项
+func ParseQualityPriority(priority []string) ([]int, []string) {
+	var ids []int
+	var unknown []string
+	for _, p := range priority {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if q, ok := ParseQuality(p); ok {
+			ids = append(ids, q.ID)
+		} else {
+			unknown = append(unknown, p)
+		}
+	}
+	return ids, unknown
+}
+
+// QualityDesc 返回画质代码对应的中文名称
+func QualityDesc(qn int) string {
+	if q, ok := LookupQuality(qn); ok {
+		return q.Name
+	}
+	return fmt.Sprintf("未知画质(%d)", qn)
+}
+
+// normalizeQualityName 规范化画质名称用于比较
+func normalizeQualityName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAPIRateLimit 默认每秒最多发起的API请求数
+const defaultAPIRateLimit = 2
+
+// rateLimiter 限速器，相邻两次放行至少间隔interval，并附加[0, jitter)的随机延迟
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	jitter   time.Duration
+	next     time.Time
+}
+
+// Wait 阻塞直到允许下一次请求，第一次调用立即返回，限速器为nil时不等待
+func (l *rateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.interval <= 0 {
+		l.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+
+	gap := l.interval
+	if l.jitter > 0 {
+		gap += time.Duration(rand.Int63n(int64(l.jitter)))
+	}
+	l.next = l.next.Add(gap)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// set 更新限速间隔和随机延迟
+func (l *rateLimiter) set(interval, jitter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = interval
+	l.jitter = jitter
+}
+
+// configureRateLimits 按配置设置本次下载所用客户端的API请求速率和分P间隔
+// 分P间隔附加最多50%的随机延迟，避免固定节奏触发风控
+// 限速器只创建一次，再次调用只更新间隔，批量下载时上一个链接的最后一个分P和下一个链接之间也保持间隔
+func configureRateLimits(config *Config) error {
+	delay, err := ParseDelay(config.DelayPerPage)
+	if err != nil {
+		return err
+	}
+	config.limiterOnce.Do(func() {
+		config.pageLimiter = &rateLimiter{}
+	})
+	config.pageLimiter.set(delay, delay/2)
+
+	var interval time.Duration
+	if config.APIRateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / config.APIRateLimit)
+	}
+	client := httpClient(config)
+	if client.apiLimiter == nil {
+		client.apiLimiter = &rateLimiter{}
+	}
+	client.apiLimiter.set(interval, 0)
+	return nil
+}
+
+// ParseDelay 解析延迟时间，纯数字按秒计算，也支持 500ms、1.5s、1m 等格式
+func ParseDelay(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("延迟时间不能为负数: %s", s)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	delay, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的延迟时间: %s", s)
+	}
+	if delay < 0 {
+		return 0, fmt.Errorf("延迟时间不能为负数: %s", s)
+	}
+	return delay, nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrRiskControl 请求被B站风控拦截，可用 errors.Is 判断
+var ErrRiskControl = errors.New("触发B站风控")
+
+// RiskControlError 风控错误详情
+type RiskControlError struct {
+	Reason    string // 拦截原因，如 HTTP 412、API错误码 -352
+	Code      int    // 接口返回的错误码，HTTP状态码或验证码页面拦截时为0
+	Retryable bool   // 是否可以通过等待恢复
+}
+
+// Error 返回错误信息，并提示可行的解决办法
+func (e *RiskControlError) Error() string {
+	return fmt.Sprintf("%s(%s)，请使用 --cookie 登录，或降低 --concurrent-tasks 和 --api-rate-limit 后重试", ErrRiskControl, e.Reason)
+}
+
+// Is 使 errors.Is(err, ErrRiskControl) 成立
+func (e *RiskControlError) Is(target error) bool {
+	return target == ErrRiskControl
+}
+
+// riskControlCodes 表示风控的API错误码，值为是否可以通过等待恢复
+// -401、-403等权限错误不属于风控，由 checkAPIResponse 返回 *APIError
+var riskControlCodes = map[int]bool{
+	-352:  true,  // 风控校验失败
+	-412:  true,  // 请求被拦截
+	87008: false, // 需要完成验证码，等待无法恢复
+}
+
+// captchaMarkers 验证码页面的特征
+var captchaMarkers = []string{"验证码_哔哩哔哩", "risk-captcha", "geetest"}
+
+// riskControlBackoff 遇到风控时的等待间隔，逐次递增
+var riskControlBackoff = []time.Duration{5 * time.Second, 15 * time.Second, 45 * time.Second}
+
+// checkRiskStatus 检查HTTP状态码是否表示风控或限流
+func checkRiskStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusPreconditionFailed:
+		return &RiskControlError{Reason: "HTTP 412", Retryable: true}
+	case http.StatusTooManyRequests:
+		return &RiskControlError{Reason: "HTTP 429 请求过于频繁", Retryable: true}
+	}
+	return nil
+}
+
+// checkRiskBody 检查响应内容是否为风控错误码或验证码页面
+func checkRiskBody(body string) error {
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") {
+		var resp struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal([]byte(trimmed), &resp) == nil {
+			if retryable, ok := riskControlCodes[resp.Code]; ok {
+				return &RiskControlError{
+					Reason:    fmt.Sprintf("API错误码 %d %s", resp.Code, resp.Message),
+					Code:      resp.Code,
+					Retryable: retryable,
+				}
+			}
+		}
+		return nil
+	}
+
+	if isCaptchaPage(body) {
+		return &RiskControlError{Reason: "遇到验证码页面", Retryable: true}
+	}
+	return nil
+}
+
+// isCaptchaPage 判断网页是否为验证码页面
+func isCaptchaPage(html string) bool {
+	for _, marker := range captchaMarkers {
+		if strings.Contains(html, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// withRiskControlBackoff 执行请求，遇到可恢复的风控时按递增间隔等待后重试
+func withRiskControlBackoff(do func() (string, error)) (string, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := do()
+
+		var riskErr *RiskControlError
+		if !errors.As(err, &riskErr) || !riskErr.Retryable || attempt >= len(riskControlBackoff) {
+			return body, err
+		}
+
+		wait := riskControlBackoff[attempt]
+		fmt.Printf("%s(%s)，%v后重试...\n", ErrRiskControl, riskErr.Reason, wait)
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// segmentWorkers 同时下载的分段数量
+const segmentWorkers = 4
+
+// downloadSegments 并行下载所有分段，并无损拼接到path
+func downloadSegments(track *Track, path string, config *Config) error {
+	ext := segmentExtension(track)
+	segmentPaths := make([]string, len(track.Segments))
+	for i := range track.Segments {
+		segmentPaths[i] = fmt.Sprintf("%s.seg%03d%s", path, i+1, ext)
+	}
+
+	fmt.Printf("共%d个分段，总大小: %s\n", len(track.Segments), formatSize(track.Size))
+
+	// 并发下载分段
+	errs := make([]error, len(track.Segments))
+	sem := make(chan struct{}, segmentWorkers)
+	var wg sync.WaitGroup
+	for i, segment := range track.Segments {
+		wg.Add(1)
+		go func(i int, segment Segment) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = downloadSegment(segment, segmentPaths[i], config)
+		}(i, segment)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("下载第%d个分段失败: %w", i+1, err)
+		}
+	}
+
+	// 单个分段直接重命名
+	if len(segmentPaths) == 1 {
+		return concatSingle(segmentPaths[0], path, config)
+	}
+
+	fmt.Printf("正在拼接%d个分段...\n", len(segmentPaths))
+	if err := concatSegments(segmentPaths, path, config); err != nil {
+		return err
+	}
+
+	// 拼接成功后删除分段文件
+	for _, segmentPath := range segmentPaths {
+		os.Remove(segmentPath)
+	}
+	return nil
+}
+
+// downloadSegment 下载单个分段，主地址失败时依次尝试备用地址
+func downloadSegment(segment Segment, path string, config *Config) error {
+	urls := append([]string{segment.URL}, segment.BackupURLs...)
+
+	var lastErr error
+	for _, url := range urls {
+		if err := downloadURL(url, path, config); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// concatSingle 处理只有一个分段的情况，扩展名一致时直接重命名，否则用FFmpeg转封装
+func concatSingle(segmentPath, path string, config *Config) error {
+	if strings.EqualFold(filepath.Ext(segmentPath), filepath.Ext(path)) {
+		return os.Rename(segmentPath, path)
+	}
+	if err := concatSegments([]string{segmentPath}, path, config); err != nil {
+		return err
+	}
+	return os.Remove(segmentPath)
+}
+
+// concatSegments 使用FFmpeg concat分离器无损拼接分段，跳过混流时同样需要拼接
+func concatSegments(segmentPaths []string, path string, config *Config) error {
+	if !commandExists(ffmpegPath(config)) {
+		return fmt.Errorf("拼接FLV分段需要FFmpeg，请安装FFmpeg或通过 --ffmpeg-path 指定")
+	}
+	listPath := path + ".concat.txt"
+
+	var list strings.Builder
+	for _, segmentPath := range segmentPaths {
+		absPath, err := filepath.Abs(segmentPath)
+		if err != nil {
+			return err
+		}
+		// concat列表中的单引号需要转义
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(absPath, "'", `'\''`))
+	}
+
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return fmt.Errorf("写入拼接列表失败: %w", err)
+	}
+	defer os.Remove(listPath)
+
+	cmd := []string{
+		ffmpegPath(config),
+		"-f", "concat",
+		"-safe", "0",
+		"-i", listPath,
+		"-c", "copy",
+		path,
+		"-y",
+	}
+	if err := executeCommand(cmd); err != nil {
+		return fmt.Errorf("拼接分段失败: %w", err)
+	}
+	return nil
+}
+
+// segmentExtension 根据轨道格式返回分段文件的扩展名
+func segmentExtension(track *Track) string {
+	if strings.Contains(strings.ToLower(track.Format), "mp4") {
+		return ".mp4"
+	}
+	return ".flv"
+}
+
+// hasEmbeddedAudio 判断轨道是否为已包含音频的分段文件
+func hasEmbeddedAudio(track *Track) bool {
+	return track != nil && len(track.Segments) > 0
+}
+
+// onlyEmbeddedAudio 判断是否只有包含音频的分段视频，没有单独的音频流
+func onlyEmbeddedAudio(tracks []*Track) bool {
+	embedded := false
+	for _, track := range tracks {
+		if track.FrameType == "audio" {
+			return false
+		}
+		embedded = embedded || hasEmbeddedAudio(track)
+	}
+	return embedded
+}
@@ -0,0 +1,408 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// trackCandidate 参与评分的候选轨道
+type trackCandidate struct {
+	track       *Track
+	qualityRank int    // 画质优先级序号，越小越优先
+	codecRank   int    // 编码优先级序号，越小越优先
+	rejected    string // 被过滤的原因，为空表示未被过滤
+}
+
+// selectVideoTrack 选择视频轨道
+//
+// 选择规则：先按分辨率、帧率、码率和文件大小上限过滤，
+// 再依次比较画质优先级、编码优先级，最后按画质和码率排序（受VideoAscending影响）。
+func selectVideoTrack(tracks []*Track, config *Config) (*Track, error) {
+	// 过滤视频轨道
+	var videoTracks []*Track
+	for _, track := range tracks {
+		if track.FrameType == "video" {
+			videoTracks = append(videoTracks, track)
+		}
+	}
+
+	if len(videoTracks) == 0 {
+		return nil, fmt.Errorf("没有找到视频轨道")
+	}
+
+	// 如果是交互式选择
+	if config.Interactive {
+		// 显示所有可选轨道
+		fmt.Println("可用的视频轨道：")
+		for i, track := range videoTracks {
+			fmt.Printf("%d. %s\n", i+1, formatVideoTrack(track))
+		}
+
+		// 选择轨道
+		fmt.Print("请选择要下载的轨道：")
+		var choice int
+		fmt.Scanln(&choice)
+		if choice < 1 || choice > len(videoTracks) {
+			return nil, fmt.Errorf("无效的选择")
+		}
+		return videoTracks[choice-1], nil
+	}
+
+	qualityPriority := cleanPriority(config.QualityPriority)
+	encodingPriority := cleanPriority(config.EncodingPriority)
+
+	candidates := make([]*trackCandidate, 0, len(videoTracks))
+	for _, track := range videoTracks {
+		candidates = append(candidates, &trackCandidate{
+			track:       track,
+			qualityRank: matchQualityRank(track, qualityPriority),
+			codecRank:   matchCodecRank(track, encodingPriority),
+			rejected:    checkVideoLimits(track, config),
+		})
+	}
+
+	accepted := filterCandidates(candidates)
+	if len(accepted) == 0 {
+		// 所有轨道都超出限制时报错，不下载用户想排除的轨道
+		return nil, limitError("视频", candidates)
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		a, b := accepted[i], accepted[j]
+		if a.qualityRank != b.qualityRank {
+			return a.qualityRank < b.qualityRank
+		}
+		if a.codecRank != b.codecRank {
+			return a.codecRank < b.codecRank
+		}
+		return videoBetter(a.track, b.track, config.VideoAscending)
+	})
+
+	if config.Debug {
+		printCandidates("视频", candidates, accepted[0], len(qualityPriority), len(encodingPriority))
+	}
+
+	return accepted[0].track, nil
+}
+
+// selectAudioTrack 选择音频轨道
+func selectAudioTrack(tracks []*Track, config *Config) (*Track, error) {
+	// 过滤音频轨道
+	var audioTracks []*Track
+	for _, track := range tracks {
+		if track.FrameType == "audio" {
+			audioTracks = append(audioTracks, track)
+		}
+	}
+
+	if len(audioTracks) == 0 {
+		return nil, fmt.Errorf("没有找到音频轨道")
+	}
+
+	// 如果是交互式选择
+	if config.Interactive {
+		// 显示所有可选轨道
+		fmt.Println("可用的音频轨道：")
+		for i, track := range audioTracks {
+			fmt.Printf("%d. %s\n", i+1, formatAudioTrack(track))
+		}
+
+		// 选择轨道
+		fmt.Print("请选择要下载的轨道：")
+		var choice int
+		fmt.Scanln(&choice)
+		if choice < 1 || choice > len(audioTracks) {
+			return nil, fmt.Errorf("无效的选择")
+		}
+		return audioTracks[choice-1], nil
+	}
+
+	candidates := make([]*trackCandidate, 0, len(audioTracks))
+	for _, track := range audioTracks {
+		candidates = append(candidates, &trackCandidate{
+			track:    track,
+			rejected: checkAudioLimits(track, config),
+		})
+	}
+
+	accepted := filterCandidates(candidates)
+	if len(accepted) == 0 {
+		return nil, limitError("音频", candidates)
+	}
+
+	preference := strings.ToLower(config.AudioPreference)
+	ascending := config.AudioAscending || preference == AudioPreferAACLowest
+	sort.SliceStable(accepted, func(i, j int) bool {
+		a, b := accepted[i].track, accepted[j].track
+		rankA, rankB := audioKindRank(a, preference), audioKindRank(b, preference)
+		if rankA != rankB {
+			return rankA < rankB
+		}
+		if a.Bandwidth != b.Bandwidth {
+			if ascending {
+				return a.Bandwidth < b.Bandwidth
+			}
+			return a.Bandwidth > b.Bandwidth
+		}
+		return false
+	})
+
+	if config.Debug {
+		printCandidates("音频", candidates, accepted[0], 0, 0)
+		fmt.Printf("Debug: 音频偏好：%s\n", audioPreferenceName(preference))
+	}
+
+	return accepted[0].track, nil
+}
+
+// 音频偏好选项
+const (
+	AudioPreferHiRes      = "hires"       // 优先Hi-Res无损(FLAC)
+	AudioPreferDolby      = "dolby"       // 优先杜比全景声(E-AC-3)
+	AudioPreferAACHighest = "aac-highest" // 只考虑AAC，选择最高码率
+	AudioPreferAACLowest  = "aac-lowest"  // 只考虑AAC，选择最低码率
+)
+
+// AudioPreferences 所有可用的音频偏好
+var AudioPreferences = []string{AudioPreferHiRes, AudioPreferDolby, AudioPreferAACHighest, AudioPreferAACLowest}
+
+// isDolbyAudio 判断是否为杜比音频
+func isDolbyAudio(track *Track) bool {
+	return track.ID == audioIDDolby || track.Codec == "E-AC-3"
+}
+
+// isHiResAudio 判断是否为Hi-Res无损音频
+func isHiResAudio(track *Track) bool {
+	return track.ID == audioIDHiRes || track.Codec == "FLAC"
+}
+
+// audioKindRank 根据音频偏好返回音频类型的排序序号，越小越优先
+// 未设置偏好时Hi-Res排在最后，在杜比和AAC中按码率选择，避免默认输出变为MKV
+func audioKindRank(track *Track, preference string) int {
+	hires, dolby := isHiResAudio(track), isDolbyAudio(track)
+	switch preference {
+	case AudioPreferHiRes:
+		if hires {
+			return 0
+		} else if dolby {
+			return 2
+		}
+		return 1
+	case AudioPreferDolby:
+		if dolby {
+			return 0
+		} else if hires {
+			return 2
+		}
+		return 1
+	case AudioPreferAACHighest, AudioPreferAACLowest:
+		if hires || dolby {
+			return 1
+		}
+		return 0
+	default:
+		if hires {
+			return 1
+		}
+		return 0
+	}
+}
+
+// audioPreferenceName 返回音频偏好的显示名称
+func audioPreferenceName(preference string) string {
+	switch preference {
+	case AudioPreferHiRes:
+		return "Hi-Res无损优先"
+	case AudioPreferDolby:
+		return "杜比全景声优先"
+	case AudioPreferAACHighest:
+		return "AAC最高码率"
+	case AudioPreferAACLowest:
+		return "AAC最低码率"
+	default:
+		return "最佳音质(不含Hi-Res)"
+	}
+}
+
+// cleanPriority 去除优先级列表中的空项和多余空格
+func cleanPriority(priority []string) []string {
+	var result []string
+	for _, p := range priority {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// matchQualityRank 返回轨道在画质优先级中的序号，未命中时返回列表长度
+// 优先级项可以是画质代码或画质表中的名称，无法识别的项按描述子串匹配
+func matchQualityRank(track *Track, priority []string) int {
+	for i, quality := range priority {
+		if q, ok := ParseQuality(quality); ok {
+			if q.ID == track.ID {
+				return i
+			}
+			continue
+		}
+		if strings.Contains(track.Description, quality) {
+			return i
+		}
+	}
+	return len(priority)
+}
+
+// matchCodecRank 返回轨道在编码优先级中的序号，未命中时返回列表长度
+func matchCodecRank(track *Track, priority []string) int {
+	for i, encoding := range priority {
+		if strings.EqualFold(track.Codec, encoding) {
+			return i
+		}
+	}
+	return len(priority)
+}
+
+// checkVideoLimits 检查视频轨道是否超出限制，返回不满足的原因
+func checkVideoLimits(track *Track, config *Config) string {
+	// 使用短边作为分辨率，兼容竖屏视频
+	resolution := track.Height
+	if track.Width > 0 && track.Width < resolution {
+		resolution = track.Width
+	}
+
+	if config.MaxResolution > 0 && resolution > config.MaxResolution {
+		return fmt.Sprintf("分辨率%dp超过上限%dp", resolution, config.MaxResolution)
+	}
+	if config.MinResolution > 0 && resolution > 0 && resolution < config.MinResolution {
+		return fmt.Sprintf("分辨率%dp低于下限%dp", resolution, config.MinResolution)
+	}
+	if config.MaxFPS > 0 && track.FPS > config.MaxFPS {
+		return fmt.Sprintf("帧率%d超过上限%d", track.FPS, config.MaxFPS)
+	}
+	return checkCommonLimits(track, config)
+}
+
+// checkAudioLimits 检查音频轨道是否超出限制，返回不满足的原因
+func checkAudioLimits(track *Track, config *Config) string {
+	return checkCommonLimits(track, config)
+}
+
+// checkCommonLimits 检查码率和文件大小限制
+func checkCommonLimits(track *Track, config *Config) string {
+	if config.MaxBitrate > 0 && track.Bandwidth > config.MaxBitrate {
+		return fmt.Sprintf("码率%dkbps超过上限%dkbps", track.Bandwidth, config.MaxBitrate)
+	}
+	// 大小未知的轨道无法判断，不做过滤
+	if config.MaxFileSize > 0 && track.Size > config.MaxFileSize {
+		return fmt.Sprintf("大小%s超过上限%s", formatSize(track.Size), formatSize(config.MaxFileSize))
+	}
+	return ""
+}
+
+// filterCandidates 返回未被过滤的候选轨道
+func filterCandidates(candidates []*trackCandidate) []*trackCandidate {
+	var accepted []*trackCandidate
+	for _, c := range candidates {
+		if c.rejected == "" {
+			accepted = append(accepted, c)
+		}
+	}
+	return accepted
+}
+
+// limitError 所有候选轨道都被过滤时返回的错误，列出未能满足的限制
+func limitError(kind string, candidates []*trackCandidate) error {
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if !seen[c.rejected] {
+			seen[c.rejected] = true
+			reasons = append(reasons, c.rejected)
+		}
+	}
+	return fmt.Errorf("没有满足限制条件的%s轨道(%s)，请放宽限制后重试", kind, strings.Join(reasons, "；"))
+}
+
+// videoBetter 在画质和编码优先级相同时比较两个视频轨道
+func videoBetter(a, b *Track, ascending bool) bool {
+	keys := [][2]int{
+		{a.ID, b.ID},
+		{a.Height * a.Width, b.Height * b.Width},
+		{a.FPS, b.FPS},
+		{a.Bandwidth, b.Bandwidth},
+	}
+	for _, k := range keys {
+		if k[0] != k[1] {
+			if ascending {
+				return k[0] < k[1]
+			}
+			return k[0] > k[1]
+		}
+	}
+	return false
+}
+
+// formatVideoTrack 格式化视频轨道信息，需要大会员的画质会额外标注
+func formatVideoTrack(track *Track) string {
+	desc := track.Description
+	if q, ok := LookupQuality(track.ID); ok && q.NeedVIP {
+		desc += " [大会员]"
+	}
+	return fmt.Sprintf("%s - %dx%d - %s - %dfps - %dkbps - %s", desc, track.Width, track.Height, track.Codec, track.FPS, track.Bandwidth, formatSize(track.Size))
+}
+
+// formatAudioTrack 格式化音频轨道信息
+func formatAudioTrack(track *Track) string {
+	return fmt.Sprintf("%s - %s - %dkbps - %s", describeAudio(track), track.Description, track.Bandwidth, formatSize(track.Size))
+}
+
+// printTracks 输出所有可用的音视频轨道
+func printTracks(tracks []*Track) {
+	fmt.Println("可用的视频流：")
+	for _, track := range tracks {
+		if track.FrameType == "video" {
+			fmt.Printf("  [%d] %s\n", track.ID, formatVideoTrack(track))
+		}
+	}
+	fmt.Println("可用的音频流：")
+	for _, track := range tracks {
+		if track.FrameType == "audio" {
+			fmt.Printf("  [%d] %s\n", track.ID, formatAudioTrack(track))
+		}
+	}
+}
+
+// printCandidates 输出候选轨道及选择原因
+func printCandidates(kind string, candidates []*trackCandidate, chosen *trackCandidate, qualityCount, codecCount int) {
+	fmt.Printf("Debug: %s轨道候选列表：\n", kind)
+	for _, c := range candidates {
+		t := c.track
+		status := "可选"
+		if c.rejected != "" {
+			status = "已过滤: " + c.rejected
+		}
+		fmt.Printf("Debug:   [%d] %s %s %dx%d %dfps %dkbps %s (%s)\n",
+			t.ID, t.Description, t.Codec, t.Width, t.Height, t.FPS, t.Bandwidth, formatSize(t.Size), status)
+	}
+
+	var reasons []string
+	if qualityCount > 0 {
+		if chosen.qualityRank < qualityCount {
+			reasons = append(reasons, fmt.Sprintf("命中画质优先级第%d项", chosen.qualityRank+1))
+		} else {
+			reasons = append(reasons, "未命中画质优先级")
+		}
+	}
+	if codecCount > 0 {
+		if chosen.codecRank < codecCount {
+			reasons = append(reasons, fmt.Sprintf("命中编码优先级第%d项", chosen.codecRank+1))
+		} else {
+			reasons = append(reasons, "未命中编码优先级")
+		}
+	}
+	reasons = append(reasons, "其余按画质和码率排序")
+	fmt.Printf("Debug: 选择%s轨道 [%d] %s %s，原因：%s\n",
+		kind, chosen.track.ID, chosen.track.Description, chosen.track.Codec, strings.Join(reasons, "，"))
+}
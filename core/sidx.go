@@ -0,0 +1,182 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteRange 文件中的字节范围，包含Start和End
+type byteRange struct {
+	Start int64
+	End   int64
+}
+
+// Length 范围的字节数
+func (r byteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// String 返回 "start-end" 格式
+func (r byteRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// parseByteRange 解析 "928-1395" 格式的字节范围
+func parseByteRange(s string) (byteRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return byteRange{}, fmt.Errorf("无效的字节范围: %q", s)
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(from), 10, 64)
+	if err != nil {
+		return byteRange{}, fmt.Errorf("无效的字节范围: %q", s)
+	}
+	end, err := strconv.ParseInt(strings.TrimSpace(to), 10, 64)
+	if err != nil || end < start {
+		return byteRange{}, fmt.Errorf("无效的字节范围: %q", s)
+	}
+	return byteRange{Start: start, End: end}, nil
+}
+
+// mediaSegment sidx中索引的一个媒体分段
+type mediaSegment struct {
+	Range    byteRange
+	Start    time.Duration // 分段开始时间
+	Duration time.Duration
+}
+
+// parseSidx 解析sidx盒子，返回分段在文件中的字节范围和时间
+// data为index_range对应的内容，indexStart为其在文件中的位置，分段偏移相对于sidx之后的第一个字节
+func parseSidx(data []byte, indexStart int64) ([]mediaSegment, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("sidx数据过短")
+	}
+	size := int64(binary.BigEndian.Uint32(data[0:4]))
+	if string(data[4:8]) != "sidx" {
+		return nil, fmt.Errorf("索引范围内不是sidx盒子: %q", data[4:8])
+	}
+	if size > int64(len(data)) || size < 12 {
+		return nil, fmt.Errorf("sidx大小无效: %d", size)
+	}
+	body := data[8:size]
+
+	version := body[0]
+	p := 4 + 4 // version/flags, reference_ID
+	need := p + 4 + 8 + 4
+	if version == 1 {
+		need = p + 4 + 16 + 4
+	}
+	if len(body) < need {
+		return nil, fmt.Errorf("sidx数据不完整")
+	}
+
+	timescale := binary.BigEndian.Uint32(body[p:])
+	p += 4
+	if timescale == 0 {
+		return nil, fmt.Errorf("sidx的timescale为0")
+	}
+
+	var earliest, firstOffset uint64
+	if version == 0 {
+		earliest = uint64(binary.BigEndian.Uint32(body[p:]))
+		firstOffset = uint64(binary.BigEndian.Uint32(body[p+4:]))
+		p += 8
+	} else {
+		earliest = binary.BigEndian.Uint64(body[p:])
+		firstOffset = binary.BigEndian.Uint64(body[p+8:])
+		p += 16
+	}
+	p += 2 // reserved
+	count := int(binary.BigEndian.Uint16(body[p:]))
+	p += 2
+	if len(body) < p+count*12 {
+		return nil, fmt.Errorf("sidx引用数量%d超出数据长度", count)
+	}
+
+	offset := indexStart + size + int64(firstOffset)
+	presentation := earliest
+
+	segments := make([]mediaSegment, 0, count)
+	for i := 0; i < count; i++ {
+		ref := binary.BigEndian.Uint32(body[p:])
+		duration := binary.BigEndian.Uint32(body[p+4:])
+		p += 12
+		if ref&0x80000000 != 0 {
+			return nil, fmt.Errorf("不支持多级sidx索引")
+		}
+		length := int64(ref & 0x7fffffff)
+		segments = append(segments, mediaSegment{
+			Range:    byteRange{Start: offset, End: offset + length - 1},
+			Start:    scaleDuration(presentation, timescale),
+			Duration: scaleDuration(uint64(duration), timescale),
+		})
+		offset += length
+		presentation += uint64(duration)
+	}
+	return segments, nil
+}
+
+// scaleDuration 将timescale为单位的时间转换为time.Duration
+func scaleDuration(value uint64, timescale uint32) time.Duration {
+	return time.Duration(float64(value) / float64(timescale) * float64(time.Second))
+}
+
+// fetchSegmentIndex 从url下载轨道的sidx并解析分段
+func fetchSegmentIndex(client *HTTPClient, url string, track *Track) ([]mediaSegment, error) {
+	if track.SegmentBase == nil {
+		return nil, fmt.Errorf("轨道%d缺少segment_base，无法读取分段索引", track.ID)
+	}
+	indexRange, err := parseByteRange(track.SegmentBase.IndexRange)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fetchByteRange(client, url, indexRange)
+	if err != nil {
+		return nil, fmt.Errorf("下载分段索引失败: %w", err)
+	}
+	return parseSidx(data, indexRange.Start)
+}
+
+// fetchByteRange 下载URL中指定范围的字节
+func fetchByteRange(client *HTTPClient, url string, r byteRange) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := copyByteRange(client, url, r, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// copyByteRange 将URL中指定范围的字节写入w
+func copyByteRange(client *HTTPClient, url string, r byteRange, w io.Writer) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", getRandomUserAgent())
+	req.Header.Set("Referer", "https://www.bilibili.com/")
+	req.Header.Set("Range", "bytes="+r.String())
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("HTTP状态码: %d，服务器不支持Range请求", resp.StatusCode)
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, r.Length()))
+	if err != nil {
+		return err
+	}
+	if n != r.Length() {
+		return fmt.Errorf("数据长度%d与请求范围%s不符", n, r)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tekintian/go-bbdown/util"
+)
+
+// maxSteinNodes 遍历互动视频剧情图的最大节点数，避免异常数据导致无限请求
+const maxSteinNodes = 500
+
+// SteinGraph 互动视频的剧情图
+type SteinGraph struct {
+	Aid          int64        `json:"aid"`
+	Bvid         string       `json:"bvid"`
+	Title        string       `json:"title"`
+	GraphVersion int64        `json:"graphVersion"`
+	RootEdgeID   int64        `json:"rootEdgeId"`
+	Nodes        []*SteinNode `json:"nodes"` // 按广度优先顺序排列，第一个为根节点
+}
+
+// SteinNode 剧情图中的一个节点，对应一段视频
+type SteinNode struct {
+	EdgeID  int64         `json:"edgeId"`
+	Cid     int64         `json:"cid"`
+	Title   string        `json:"title"`
+	Option  string        `json:"option,omitempty"` // 第一次到达该节点时选择的选项
+	IsLeaf  bool          `json:"isLeaf"`           // 结局节点
+	Choices []SteinChoice `json:"choices,omitempty"`
+}
+
+// SteinChoice 节点结束时的一个选项
+type SteinChoice struct {
+	Question string `json:"question,omitempty"` // 选项所属的问题
+	Option   string `json:"option"`
+	EdgeID   int64  `json:"edgeId"` // 选择后跳转的节点
+	Cid      int64  `json:"cid"`
+}
+
+// steinEdgeInfo edgeinfo_v2接口返回的节点信息
+type steinEdgeInfo struct {
+	Title  string `json:"title"`
+	EdgeID int64  `json:"edge_id"`
+	IsLeaf int    `json:"is_leaf"`
+	Edges  struct {
+		Questions []struct {
+			Title   string `json:"title"`
+			Choices []struct {
+				ID     int64  `json:"id"`
+				Cid    int64  `json:"cid"`
+				Option string `json:"option"`
+			} `json:"choices"`
+		} `json:"questions"`
+	} `json:"edges"`
+}
+
+// fetchSteinGraph 从根节点开始广度优先遍历互动视频的剧情图
+func fetchSteinGraph(vinfo *VInfo, config *Config) (*SteinGraph, error) {
+	if len(vinfo.Pages) == 0 {
+		return nil, fmt.Errorf("互动视频没有分P信息")
+	}
+	rootCid := vinfo.Pages[0].Cid
+
+	version, err := fetchGraphVersion(vinfo.Aid, rootCid, config)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &SteinGraph{Aid: vinfo.Aid, Bvid: vinfo.Bvid, Title: vinfo.Title, GraphVersion: version}
+
+	// 根节点的edge_id由接口返回，请求时传0
+	root := &SteinNode{Cid: rootCid, Title: vinfo.Pages[0].Part}
+	queue := []*SteinNode{root}
+	seen := make(map[int64]bool)
+	truncated := false
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		info, err := fetchEdgeInfo(vinfo.Aid, version, node.EdgeID, config)
+		if err != nil {
+			return nil, err
+		}
+		if node.EdgeID == 0 {
+			node.EdgeID = info.EdgeID
+			graph.RootEdgeID = info.EdgeID
+		}
+		if info.Title != "" {
+			node.Title = info.Title
+		}
+		node.IsLeaf = info.IsLeaf == 1
+		seen[node.EdgeID] = true
+		graph.Nodes = append(graph.Nodes, node)
+
+		for _, question := range info.Edges.Questions {
+			for _, choice := range question.Choices {
+				node.Choices = append(node.Choices, SteinChoice{
+					Question: question.Title,
+					Option:   choice.Option,
+					EdgeID:   choice.ID,
+					Cid:      choice.Cid,
+				})
+				if seen[choice.ID] {
+					continue
+				}
+				if len(graph.Nodes)+len(queue) >= maxSteinNodes {
+					truncated = true
+					continue
+				}
+				seen[choice.ID] = true
+				queue = append(queue, &SteinNode{EdgeID: choice.ID, Cid: choice.Cid, Option: choice.Option})
+			}
+		}
+	}
+	if truncated {
+		fmt.Printf("互动视频节点超过%d个，忽略其余节点\n", maxSteinNodes)
+	}
+	return graph, nil
+}
+
+// fetchGraphVersion 从播放器接口获取剧情图版本
+func fetchGraphVersion(aid, cid int64, config *Config) (int64, error) {
+	client := httpClient(config)
+	api := client.apiURL(config.Host, "/x/player/wbi/v2?")
+	resp, err := client.GetWBISource(api, map[string]string{
+		"aid": strconv.FormatInt(aid, 10),
+		"cid": strconv.FormatInt(cid, 10),
+	}, config.UserAgent)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkAPIResponse(api, resp); err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Data struct {
+			Interaction *struct {
+				GraphVersion int64 `json:"graph_version"`
+			} `json:"interaction"`
+		} `json:"data"`
+	}
+	if err := parseJSON(resp, &response); err != nil {
+		return 0, err
+	}
+	if response.Data.Interaction == nil || response.Data.Interaction.GraphVersion == 0 {
+		return 0, fmt.Errorf("获取互动视频剧情图版本失败")
+	}
+	return response.Data.Interaction.GraphVersion, nil
+}
+
+// fetchEdgeInfo 获取剧情图中一个节点的信息和选项，edgeID为0时返回根节点
+func fetchEdgeInfo(aid, graphVersion, edgeID int64, config *Config) (*steinEdgeInfo, error) {
+	client := httpClient(config)
+	query := fmt.Sprintf("/x/stein/edgeinfo_v2?aid=%d&graph_version=%d", aid, graphVersion)
+	if edgeID != 0 {
+		query += fmt.Sprintf("&edge_id=%d", edgeID)
+	}
+	api := client.apiURL(config.Host, query)
+	resp, err := client.GetWebSource(api, config.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAPIResponse(api, resp); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data steinEdgeInfo `json:"data"`
+	}
+	if err := parseJSON(resp, &response); err != nil {
+		return nil, err
+	}
+	return &response.Data, nil
+}
+
+// pages 将节点转换为分P，同一段视频(cid)只保留第一次出现的节点
+// 分P标题使用到达该节点的选项，根节点使用节点标题
+func (g *SteinGraph) pages() []Page {
+	var pages []Page
+	seen := make(map[int64]bool)
+	for _, node := range g.Nodes {
+		if seen[node.Cid] {
+			continue
+		}
+		seen[node.Cid] = true
+
+		part := node.Option
+		if part == "" {
+			part = node.Title
+		}
+		pages = append(pages, Page{
+			Index: len(pages) + 1,
+			Cid:   node.Cid,
+			Title: node.Title,
+			Part:  part,
+		})
+	}
+	return pages
+}
+
+// dot 将剧情图转换为Graphviz DOT格式，结局节点使用双边框
+func (g *SteinGraph) dot() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %s {\n", dotQuote(g.Title))
+	sb.WriteString("  node [shape=box];\n")
+	for _, node := range g.Nodes {
+		attrs := "label=" + dotQuote(node.Title)
+		if node.IsLeaf {
+			attrs += ", peripheries=2"
+		}
+		fmt.Fprintf(&sb, "  %d [%s];\n", node.EdgeID, attrs)
+	}
+	for _, node := range g.Nodes {
+		for _, choice := range node.Choices {
+			fmt.Fprintf(&sb, "  %d -> %d [label=%s];\n", node.EdgeID, choice.EdgeID, dotQuote(choice.Option))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotQuote 生成DOT格式的带引号字符串
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// writeSteinGraph 将剧情图按格式(json或dot)保存到工作目录
+func writeSteinGraph(graph *SteinGraph, format string, workDir string) error {
+	var data []byte
+	switch format {
+	case "json":
+		var err error
+		if data, err = json.MarshalIndent(graph, "", "  "); err != nil {
+			return err
+		}
+	case "dot":
+		data = []byte(graph.dot())
+	default:
+		return fmt.Errorf("不支持的剧情图格式: %s，可选 json 或 dot", format)
+	}
+
+	if workDir != "" {
+		if err := os.MkdirAll(workDir, 0755); err != nil {
+			return fmt.Errorf("创建工作目录失败: %w", err)
+		}
+	}
+	path := filepath.Join(workDir, util.CleanFilename(graph.Title)+".stein."+format)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存剧情图失败: %w", err)
+	}
+	fmt.Printf("剧情图已保存: %s\n", path)
+	return nil
+}
+
+// expandSteinGate 遍历互动视频的剧情图，用所有节点替换视频的分P
+func expandSteinGate(vinfo *VInfo, config *Config) error {
+	graph, err := fetchSteinGraph(vinfo, config)
+	if err != nil {
+		return fmt.Errorf("遍历互动视频剧情图失败: %w", err)
+	}
+	vinfo.Pages = graph.pages()
+	fmt.Printf("互动视频共%d个节点，%d段视频\n", len(graph.Nodes), len(vinfo.Pages))
+
+	if config.SteinGraph != "" {
+		return writeSteinGraph(graph, config.SteinGraph, config.WorkDir)
+	}
+	return nil
+}
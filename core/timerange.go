@@ -0,0 +1,262 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTimeRange 解析时间范围，如 00:10:00-00:15:30、10:00-15:30 或 600-930(秒)
+func ParseTimeRange(s string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("无效的时间范围: %s，格式为 开始-结束，如 00:10:00-00:15:30", s)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("无效的时间范围: %s，结束时间需要晚于开始时间", s)
+	}
+	return start, end, nil
+}
+
+// parseClock 解析 [[时:]分:]秒 格式的时间，秒可以带小数
+func parseClock(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if s == "" || len(parts) > 3 {
+		return 0, fmt.Errorf("无效的时间: %q", s)
+	}
+
+	var total float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 || (i > 0 && value >= 60) {
+			return 0, fmt.Errorf("无效的时间: %q", s)
+		}
+		// 只有最后一段(秒)允许小数
+		if i < len(parts)-1 && value != float64(int(value)) {
+			return 0, fmt.Errorf("无效的时间: %q", s)
+		}
+		total = total*60 + value
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// formatClock 将时长格式化为 时.分.秒，用于文件名
+func formatClock(d time.Duration) string {
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%02d.%02d.%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// rangeTrack 按时间范围下载的轨道，Offset为下载的第一个分段在原视频中的开始时间
+type rangeTrack struct {
+	Track  *Track
+	Path   string
+	Offset time.Duration
+}
+
+// downloadPageRange 只下载所选轨道中覆盖时间范围的分段，再用FFmpeg剪切
+// 分段边界通常不在关键帧精确位置，SDR视频重新编码以精确剪切，HDR和杜比视界按关键帧剪切
+func downloadPageRange(task pageTask, videoTrack *Track, audioTracks []*Track, tempDir string, config *Config) error {
+	start, end, err := ParseTimeRange(config.TimeRange)
+	if err != nil {
+		return err
+	}
+	if !commandExists(ffmpegPath(config)) {
+		return fmt.Errorf("按时间范围下载需要FFmpeg进行剪切")
+	}
+
+	var tracks []*rangeTrack
+	if videoTrack != nil {
+		tracks = append(tracks, &rangeTrack{Track: videoTrack, Path: filepath.Join(tempDir, fmt.Sprintf("video_%d.range.mp4", videoTrack.ID))})
+	}
+	for _, track := range audioTracks {
+		tracks = append(tracks, &rangeTrack{Track: track, Path: filepath.Join(tempDir, strings.TrimSuffix(tempAudioName(track), ".mp4")+".range.mp4")})
+	}
+
+	errs := runConcurrent(len(tracks), len(tracks), func(i int) error {
+		offset, err := downloadTrackRange(tracks[i].Track, tracks[i].Path, start, end, config)
+		tracks[i].Offset = offset
+		return err
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	label := fmt.Sprintf(" [%s-%s]", formatClock(start), formatClock(end))
+	fileName := filepath.Join(config.WorkDir, task.fileName())
+
+	// 只有音频时每条音轨单独剪切
+	if videoTrack == nil {
+		multiAudio := len(tracks) > 1
+		for i, track := range tracks {
+			outputPath := audioBaseName(fileName, audioTracks[i], multiAudio) + label + rawAudioExtension(track.Path)
+			if err := cutRange([]*rangeTrack{track}, start, end, outputPath, config); err != nil {
+				return err
+			}
+			fmt.Printf("音频片段已保存: %s\n", outputPath)
+		}
+		return nil
+	}
+
+	audioPaths := make([]string, len(audioTracks))
+	for i := range audioTracks {
+		audioPaths[i] = tracks[i+1].Path
+	}
+	// 与完整下载一样在文件名中标记HDR和杜比视界
+	if dynamic := dynamicRangeLabel(videoTrack); dynamic != "" {
+		fileName = fmt.Sprintf("%s [%s]", fileName, dynamic)
+		fmt.Printf("%s视频直接复制以保留色彩元数据，片段从起点之前最近的关键帧开始\n", dynamic)
+	}
+	outputPath := fileName + label + muxContainer(audioPaths, config)
+	if err := cutRange(tracks, start, end, outputPath, config); err != nil {
+		return err
+	}
+	fmt.Printf("视频片段已保存: %s\n", outputPath)
+	return nil
+}
+
+// downloadTrackRange 根据sidx索引下载初始化段和覆盖[start, end)的连续分段，
+// 组成可独立播放的fMP4文件，返回第一个分段的开始时间，主地址失败时依次尝试备用地址
+func downloadTrackRange(track *Track, path string, start, end time.Duration, config *Config) (time.Duration, error) {
+	var lastErr error
+	for _, url := range trackURLs(track) {
+		offset, err := downloadTrackRangeFrom(url, track, path, start, end, config)
+		if err == nil {
+			return offset, nil
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+// downloadTrackRangeFrom 从指定地址下载轨道的时间范围
+func downloadTrackRangeFrom(url string, track *Track, path string, start, end time.Duration, config *Config) (time.Duration, error) {
+	client := httpClient(config)
+	if track.SegmentBase == nil {
+		return 0, fmt.Errorf("轨道%d没有DASH索引，FLV分段格式不支持按时间范围下载", track.ID)
+	}
+	initRange, err := parseByteRange(track.SegmentBase.Initialization)
+	if err != nil {
+		return 0, err
+	}
+	segments, err := fetchSegmentIndex(client, url, track)
+	if err != nil {
+		return 0, err
+	}
+
+	first, last := -1, -1
+	for i, segment := range segments {
+		if segment.Start+segment.Duration > start && segment.Start < end {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return 0, fmt.Errorf("时间范围超出视频时长")
+	}
+
+	span := byteRange{Start: segments[first].Range.Start, End: segments[last].Range.End}
+	fmt.Printf("正在下载%s片段：%s - %s，%s\n", frameTypeName(track), formatDuration(int(segments[first].Start.Seconds())),
+		formatDuration(int((segments[last].Start + segments[last].Duration).Seconds())), formatSize(initRange.Length()+span.Length()))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	release := acquireConnection(config)
+	err = copyByteRange(client, url, initRange, file)
+	if err == nil {
+		err = copyByteRange(client, url, span, file)
+	}
+	release()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("下载片段失败: %w", err)
+	}
+	return segments[first].Start, nil
+}
+
+// cutRange 使用FFmpeg从下载的片段中剪切[start, end)，音频直接复制
+// 每个输入按各自第一个分段的开始时间换算seek位置
+func cutRange(tracks []*rangeTrack, start, end time.Duration, outputPath string, config *Config) error {
+	cmd := []string{ffmpegPath(config)}
+	for _, track := range tracks {
+		cmd = append(cmd, "-ss", ffmpegSeconds(start-track.Offset), "-i", track.Path)
+	}
+
+	audioIndex := 0
+	for i, track := range tracks {
+		if track.Track.FrameType == "video" {
+			cmd = append(cmd, "-map", fmt.Sprintf("%d:v", i))
+			continue
+		}
+		cmd = append(cmd, "-map", fmt.Sprintf("%d:a", i))
+		if !config.SimplyMux && track.Track.Language != "" {
+			cmd = append(cmd, fmt.Sprintf("-metadata:s:a:%d", audioIndex), "language="+iso6392(track.Track.Language))
+		}
+		audioIndex++
+	}
+
+	cmd = append(cmd, "-t", ffmpegSeconds(end-start))
+	if video := tracks[0].Track; video.FrameType == "video" {
+		cmd = append(cmd, rangeVideoArgs(video, strings.ToLower(filepath.Ext(outputPath)))...)
+		if !config.SimplyMux {
+			cmd = append(cmd, hdrMetadataArgs(video)...)
+		}
+	}
+	cmd = append(cmd, "-c:a", "copy", outputPath, "-y")
+
+	if err := executeCommand(cmd); err != nil {
+		return fmt.Errorf("剪切片段失败: %w", err)
+	}
+	return nil
+}
+
+// rangeVideoArgs 返回剪切视频的编码参数
+// SDR视频使用与原视频相同的编码格式重新编码以精确剪切；HDR和杜比视界重新编码会丢失
+// 色彩和动态元数据，改为直接复制，从起点之前最近的关键帧开始
+func rangeVideoArgs(track *Track, container string) []string {
+	if dynamicRangeLabel(track) != "" {
+		return append([]string{"-c:v", "copy"}, hdrMuxArgs(track, container)...)
+	}
+
+	switch track.Codec {
+	case "HEVC":
+		args := []string{"-c:v", "libx265", "-preset", "fast", "-crf", "20"}
+		if container == ".mp4" || container == ".mov" {
+			args = append(args, "-tag:v", "hvc1")
+		}
+		return args
+	case "AV1":
+		return []string{"-c:v", "libsvtav1", "-preset", "8", "-crf", "30"}
+	default:
+		return []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "18"}
+	}
+}
+
+// ffmpegSeconds 将时长格式化为FFmpeg使用的秒数
+func ffmpegSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// frameTypeName 轨道类型的中文名称
+func frameTypeName(track *Track) string {
+	if track.FrameType == "video" {
+		return "视频"
+	}
+	return "音频"
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tekintian/go-bbdown/util"
+)
+
+// wbiRejectedCode WBI签名校验失败时接口返回的错误码
+const wbiRejectedCode = -352
+
+// wbiKeyCache WBI混合密钥缓存，B站每天更换一次密钥
+type wbiKeyCache struct {
+	mu      sync.Mutex
+	key     string
+	fetched time.Time
+}
+
+// sharedWBIKeys 所有HTTP客户端共享的WBI密钥缓存
+var sharedWBIKeys = &wbiKeyCache{}
+
+// invalidate 清除缓存的密钥，下次使用时重新获取
+func (c *wbiKeyCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key = ""
+}
+
+// wbiKeys 返回客户端使用的密钥缓存
+func (h *HTTPClient) wbiKeys() *wbiKeyCache {
+	if h.wbiCache == nil {
+		return sharedWBIKeys
+	}
+	return h.wbiCache
+}
+
+// WBIKey 返回WBI混合密钥，优先使用当天缓存，跨天或缓存被清除后重新从nav接口获取
+func (h *HTTPClient) WBIKey() (string, error) {
+	cache := h.wbiKeys()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	if cache.key != "" && cache.fetched.Format("2006-01-02") == now.Format("2006-01-02") {
+		return cache.key, nil
+	}
+
+	key, err := GetWBIKey(h)
+	if err != nil {
+		return "", err
+	}
+	cache.key = key
+	cache.fetched = now
+	return key, nil
+}
+
+// GetWBISource 对参数进行WBI签名后发送GET请求，prefix为带?的接口地址
+// 接口返回-352时密钥可能已失效，清除缓存后重新签名一次
+func (h *HTTPClient) GetWBISource(prefix string, params map[string]string, userAgent string) (string, error) {
+	return withRiskControlBackoff(func() (string, error) {
+		resp, err := h.getWBISource(prefix, params, userAgent)
+		if isWBIRejected(err) {
+			h.wbiKeys().invalidate()
+			resp, err = h.getWBISource(prefix, params, userAgent)
+		}
+		return resp, err
+	})
+}
+
+// getWBISource 使用当前密钥签名并发送一次请求
+func (h *HTTPClient) getWBISource(prefix string, params map[string]string, userAgent string) (string, error) {
+	key, err := h.WBIKey()
+	if err != nil {
+		return "", err
+	}
+	return h.getWebSource(prefix+util.WBISign(params, key, time.Now().Unix()), userAgent)
+}
+
+// isWBIRejected 判断错误是否为WBI签名校验失败
+func isWBIRejected(err error) bool {
+	var riskErr *RiskControlError
+	return errors.As(err, &riskErr) && riskErr.Code == wbiRejectedCode
+}
@@ -7,7 +7,7 @@ echo "================================"
 
 # 运行util包的测试
 echo "运行 util 包测试..."
-go test ./tests/util -v
+go test ./tests/util ./tests/core ./tests/integration -v
 
 # 检查测试结果
 if [ $? -eq 0 ]; then
@@ -34,7 +34,7 @@ fi
 
 echo ""
 echo "运行覆盖率统计..."
-go test ./tests/util -cover
+go test ./tests/util ./tests/core -cover
 
 echo ""
 echo "测试完成！"
\ No newline at end of file
@@ -0,0 +1,83 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     *core.APIError
+		target  error
+		matched bool
+	}{
+		{name: "视频不存在", err: &core.APIError{Code: -404, Message: "啥都木有"}, target: core.ErrVideoNotFound, matched: true},
+		{name: "未登录", err: &core.APIError{Code: -101, Message: "账号未登录"}, target: core.ErrNotLoggedIn, matched: true},
+		{name: "未认证", err: &core.APIError{Code: -401, Message: "未认证"}, target: core.ErrNotLoggedIn, matched: true},
+		{name: "地区限制", err: &core.APIError{Code: -10403, Message: "抱歉您所在地区不可观看！"}, target: core.ErrRegionLocked, matched: true},
+		{name: "大会员限制", err: &core.APIError{Code: -10403, Message: "大会员专享限制"}, target: core.ErrVIPOnly, matched: true},
+		{name: "大会员限制不算地区限制", err: &core.APIError{Code: -10403, Message: "大会员专享限制"}, target: core.ErrRegionLocked},
+		{name: "未知错误码", err: &core.APIError{Code: -500, Message: "服务器错误"}, target: core.ErrVideoNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 经过包装后仍能识别
+			err := fmt.Errorf("获取视频信息失败: %w", tt.err)
+			if got := errors.Is(err, tt.target); got != tt.matched {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.matched)
+			}
+
+			var apiErr *core.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.err.Code {
+				t.Errorf("errors.As() 未得到原始错误码 %d", tt.err.Code)
+			}
+		})
+	}
+}
+
+func TestAccessDeniedIsNotRiskControl(t *testing.T) {
+	body := `{"code":-403,"message":"访问权限不足"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	// 权限错误由调用方按 *APIError 处理，不会被当作风控而停止其他任务
+	resp, err := core.NewHTTPClient().GetWebSource(server.URL, "")
+	if err != nil {
+		t.Fatalf("GetWebSource() error = %v", err)
+	}
+	if resp != body {
+		t.Errorf("GetWebSource() = %s, want %s", resp, body)
+	}
+}
+
+func TestCaptchaCodeIsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, `{"code":87008,"message":"需要验证码"}`)
+	}))
+	defer server.Close()
+
+	// 需要验证码时返回风控错误，但等待无法恢复，不重试
+	_, err := core.NewHTTPClient().GetWebSource(server.URL, "")
+	if !errors.Is(err, core.ErrRiskControl) {
+		t.Fatalf("GetWebSource() error = %v, want ErrRiskControl", err)
+	}
+	var riskErr *core.RiskControlError
+	if !errors.As(err, &riskErr) || riskErr.Code != 87008 || riskErr.Retryable {
+		t.Errorf("RiskControlError = %+v, want Code 87008 且不可重试", riskErr)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("请求%d次, want 1", got)
+	}
+}
@@ -0,0 +1,64 @@
+package core_test
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+func TestRecordReplay(t *testing.T) {
+	const body = `{"code":0,"data":{"title":"测试视频"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		http.SetCookie(w, &http.Cookie{Name: "SESSDATA", Value: "secret-session"})
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(body))
+		gz.Close()
+	}))
+
+	dir := t.TempDir()
+	recorder := core.NewHTTPClientWithOptions(core.HTTPOptions{RecordDir: dir})
+	got, err := recorder.GetWebSource(server.URL+"/x/web-interface/view?bvid=BV1xx411c7mD&access_key=secret-token&wts=1", "")
+	server.Close()
+	if err != nil {
+		t.Fatalf("录制 GetWebSource() error = %v", err)
+	}
+	if got != body {
+		t.Fatalf("录制 GetWebSource() = %q, want %q", got, body)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("录制文件数量 = %d, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "secret-session"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("录制文件包含未隐藏的敏感信息 %q", secret)
+		}
+	}
+
+	// 回放时wts不同也能匹配，且不访问网络
+	replayer := core.NewHTTPClientWithOptions(core.HTTPOptions{ReplayDir: dir})
+	got, err = replayer.GetWebSource(server.URL+"/x/web-interface/view?bvid=BV1xx411c7mD&access_key=other-token&wts=2", "")
+	if err != nil {
+		t.Fatalf("回放 GetWebSource() error = %v", err)
+	}
+	if got != body {
+		t.Errorf("回放 GetWebSource() = %q, want %q", got, body)
+	}
+
+	if _, err := replayer.GetWebSource(server.URL+"/x/web-interface/view?bvid=BV1yy411c7mD", ""); err == nil {
+		t.Error("回放未录制的请求应返回错误")
+	}
+}
@@ -0,0 +1,116 @@
+package core_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+// countingTransport 统计经过的请求数
+type countingTransport struct {
+	next  http.RoundTripper
+	count atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count.Add(1)
+	return t.next.RoundTrip(req)
+}
+
+func TestHTTPClientBaseURLsAndTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x/web-interface/nav" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"code":0,"data":{"wbi_img":{`+
+			`"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",`+
+			`"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	client := core.NewHTTPClientWithOptions(core.HTTPOptions{
+		Transport: transport,
+		BaseURLs:  map[string]string{"api.bilibili.com": server.URL},
+	})
+
+	key, err := core.GetWBIKey(client)
+	if err != nil {
+		t.Fatalf("GetWBIKey() error = %v", err)
+	}
+	if want := "ea1db124af3c7062474693fa704f4ff8"; key != want {
+		t.Errorf("GetWBIKey() = %v, want %v", key, want)
+	}
+	if transport.count.Load() != 1 {
+		t.Errorf("自定义Transport收到%d个请求, want 1", transport.count.Load())
+	}
+}
+
+func TestGetWebSourceRefererWithBaseURLs(t *testing.T) {
+	var referer atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referer.Store(r.Header.Get("Referer"))
+		fmt.Fprint(w, `{"code":0}`)
+	}))
+	defer server.Close()
+
+	client := core.NewHTTPClientWithOptions(core.HTTPOptions{
+		BaseURLs: map[string]string{
+			"api.bilibili.com": server.URL,
+			"api.biliintl.com": server.URL,
+		},
+	})
+
+	// Referer按替换前的主机名决定，不受实际请求地址影响
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://api.bilibili.com/x/web-interface/view?bvid=BV1xx411c7mD", want: "https://www.bilibili.com/"},
+		{url: "https://api.biliintl.com/intl/gateway/web/playurl", want: ""},
+	}
+	for _, tt := range tests {
+		if _, err := client.GetWebSource(tt.url, ""); err != nil {
+			t.Fatalf("GetWebSource(%s) error = %v", tt.url, err)
+		}
+		if got := referer.Load(); got != tt.want {
+			t.Errorf("GetWebSource(%s) Referer = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestGetWebSourceSendsCookieToBilibili(t *testing.T) {
+	var cookie atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie.Store(r.Header.Get("Cookie"))
+		fmt.Fprint(w, `{"code":0}`)
+	}))
+	defer server.Close()
+
+	client := core.NewHTTPClientWithOptions(core.HTTPOptions{
+		BaseURLs: map[string]string{"api.bilibili.com": server.URL},
+		Cookie:   "SESSDATA=secret",
+	})
+
+	// 登录Cookie只发送给B站的主机
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://api.bilibili.com/x/web-interface/nav", want: "SESSDATA=secret"},
+		{url: server.URL + "/other", want: ""},
+	}
+	for _, tt := range tests {
+		if _, err := client.GetWebSource(tt.url, ""); err != nil {
+			t.Fatalf("GetWebSource(%s) error = %v", tt.url, err)
+		}
+		if got := cookie.Load(); got != tt.want {
+			t.Errorf("GetWebSource(%s) Cookie = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
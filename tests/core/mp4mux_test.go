@@ -0,0 +1,179 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+// box 生成MP4盒子
+func box(boxType string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	header := binary.BigEndian.AppendUint32(nil, uint32(len(data)+8))
+	return append(append(header, boxType...), data...)
+}
+
+// fullBox 生成版本和标志为0的全盒子
+func fullBox(boxType string, flags uint32, payload ...[]byte) []byte {
+	return box(boxType, append([][]byte{binary.BigEndian.AppendUint32(nil, flags)}, payload...)...)
+}
+
+// u32 将多个整数编码为大端字节
+func u32(values ...uint32) []byte {
+	var data []byte
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint32(data, v)
+	}
+	return data
+}
+
+// writeFragmentedMP4 生成单轨道的fMP4文件，每个分片包含若干样本
+func writeFragmentedMP4(t *testing.T, path, handler string, fragments [][][]byte) {
+	t.Helper()
+
+	tkhd := fullBox("tkhd", 3, make([]byte, 8), u32(1), make([]byte, 64))
+	mdhd := fullBox("mdhd", 0, make([]byte, 8), u32(1000, 0), []byte{0x55, 0xc4, 0, 0})
+	hdlr := fullBox("hdlr", 0, u32(0), []byte(handler), make([]byte, 13))
+	stsd := fullBox("stsd", 0, u32(1), box("test", make([]byte, 8)))
+	stbl := box("stbl", stsd, fullBox("stts", 0, u32(0)), fullBox("stsc", 0, u32(0)), fullBox("stsz", 0, u32(0, 0)), fullBox("stco", 0, u32(0)))
+	minf := box("minf", fullBox("nmhd", 0), stbl)
+	trak := box("trak", tkhd, box("mdia", mdhd, hdlr, minf))
+	mvex := box("mvex", fullBox("trex", 0, u32(1, 1, 40, 0, 0)))
+	moov := box("moov", fullBox("mvhd", 0, make([]byte, 96)), trak, mvex)
+
+	file := append(box("ftyp", []byte("iso6"), u32(0)), moov...)
+	var decodeTime uint32
+	for i, samples := range fragments {
+		var entries []byte
+		for _, sample := range samples {
+			entries = append(entries, u32(uint32(len(sample)))...)
+		}
+		moof := func(dataOffset uint32) []byte {
+			traf := box("traf",
+				fullBox("tfhd", 0x020000, u32(1)),
+				fullBox("tfdt", 0, u32(decodeTime)),
+				fullBox("trun", 0x000201, u32(uint32(len(samples)), dataOffset), entries),
+			)
+			return box("moof", fullBox("mfhd", 0, u32(uint32(i+1))), traf)
+		}
+		size := len(moof(0))
+		file = append(file, moof(uint32(size+8))...)
+		file = append(file, box("mdat", samples...)...)
+		decodeTime += uint32(len(samples)) * 40
+	}
+
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// topLevelBoxes 返回顶层盒子的类型和位置
+func topLevelBoxes(data []byte) (types []string, offsets map[string]int) {
+	offsets = make(map[string]int)
+	for p := 0; p+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[p:]))
+		boxType := string(data[p+4 : p+8])
+		types = append(types, boxType)
+		offsets[boxType] = p
+		if size < 8 {
+			break
+		}
+		p += size
+	}
+	return types, offsets
+}
+
+// firstChunkOffsets 返回moov中每个轨道第一个数据块的位置
+func firstChunkOffsets(data []byte) []int {
+	var result []int
+	for p := 0; ; {
+		idx := bytes.Index(data[p:], []byte("stco"))
+		if idx < 0 {
+			return result
+		}
+		p += idx + 4
+		result = append(result, int(binary.BigEndian.Uint32(data[p+8:])))
+	}
+}
+
+func TestMuxMP4(t *testing.T) {
+	dir := t.TempDir()
+	videoPath := filepath.Join(dir, "video.m4s")
+	audioPath := filepath.Join(dir, "audio.m4s")
+	writeFragmentedMP4(t, videoPath, "vide", [][][]byte{
+		{[]byte("V0-key"), []byte("V1")},
+		{[]byte("V2-key"), []byte("V3")},
+	})
+	writeFragmentedMP4(t, audioPath, "soun", [][][]byte{
+		{[]byte("A0"), []byte("A1"), []byte("A2")},
+	})
+
+	for _, faststart := range []bool{true, false} {
+		output := filepath.Join(dir, "output.mp4")
+		err := core.MuxMP4([]string{videoPath, audioPath}, output, core.MP4MuxOptions{
+			FastStart: faststart,
+			Languages: []string{"", "jpn"},
+		})
+		if err != nil {
+			t.Fatalf("MuxMP4(faststart=%v) error = %v", faststart, err)
+		}
+
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		types, offsets := topLevelBoxes(data)
+		want := []string{"ftyp", "mdat", "moov"}
+		if faststart {
+			want = []string{"ftyp", "moov", "mdat"}
+		}
+		if len(types) != len(want) {
+			t.Fatalf("faststart=%v 顶层盒子 = %v, want %v", faststart, types, want)
+		}
+		for i := range want {
+			if types[i] != want[i] {
+				t.Fatalf("faststart=%v 顶层盒子 = %v, want %v", faststart, types, want)
+			}
+		}
+
+		// mdat按时间交错存放：视频分片1、音频分片1、视频分片2
+		mdat := data[offsets["mdat"]+8 : offsets["mdat"]+int(binary.BigEndian.Uint32(data[offsets["mdat"]:]))]
+		if got, want := string(mdat), "V0-keyV1A0A1A2V2-keyV3"; got != want {
+			t.Errorf("faststart=%v mdat = %q, want %q", faststart, got, want)
+		}
+
+		moov := data[offsets["moov"]:]
+		chunkOffsets := firstChunkOffsets(moov)
+		if len(chunkOffsets) != 2 {
+			t.Fatalf("faststart=%v stco数量 = %d, want 2", faststart, len(chunkOffsets))
+		}
+		if got := string(data[chunkOffsets[0] : chunkOffsets[0]+6]); got != "V0-key" {
+			t.Errorf("faststart=%v 视频数据块位置错误，读取到 %q", faststart, got)
+		}
+		if got := string(data[chunkOffsets[1] : chunkOffsets[1]+2]); got != "A0" {
+			t.Errorf("faststart=%v 音频数据块位置错误，读取到 %q", faststart, got)
+		}
+
+		// 音频轨道的mdhd语言应为jpn
+		if !bytes.Contains(moov, []byte{0x2a, 0x0e}) {
+			t.Errorf("faststart=%v 未写入音频语言", faststart)
+		}
+	}
+}
+
+func TestMuxMP4InvalidInput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(input, box("ftyp", []byte("isom"), u32(0)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := core.MuxMP4([]string{input}, filepath.Join(dir, "output.mp4"), core.MP4MuxOptions{}); err == nil {
+		t.Error("MuxMP4() 对没有moov的文件应返回错误")
+	}
+}
@@ -0,0 +1,130 @@
+package core_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+// staticTransport 对所有请求返回同一个JSON响应
+type staticTransport string
+
+func (t staticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(t))),
+		Request:    req,
+	}, nil
+}
+
+// extractTracks 使用TV接口(无需WBI签名)解析给定的playurl响应
+func extractTracks(t *testing.T, playJson string) ([]*core.Track, error) {
+	t.Helper()
+	config := core.DefaultConfig()
+	client := core.NewHTTPClientWithOptions(core.HTTPOptions{Transport: staticTransport(playJson)})
+	parser := core.NewParserWithClient(config, client)
+	return parser.ExtractTracks("", "1", "1", "2", "", true, false, false, "0")
+}
+
+const dashVideoJSON = `{"id":80,"base_url":"https://upos/video.m4s","backup_url":["https://backup/video.m4s"],` +
+	`"bandwidth":2000000,"mime_type":"video/mp4","codecs":"avc1.640032","codecid":7,"width":1920,"height":1080,` +
+	`"frame_rate":"29.970","sar":"1:1","start_with_sap":1,` +
+	`"segment_base":{"initialization":"0-927","index_range":"928-1395"},"size":1024}`
+
+const dashAudioJSON = `{"id":30280,"base_url":"https://upos/audio.m4s","bandwidth":192000,"mime_type":"audio/mp4",` +
+	`"codecs":"mp4a.40.2","codecid":0,"segment_base":{"initialization":"0-817","index_range":"818-1285"}}`
+
+func TestParsePlayDataDash(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"data节点", `{"code":0,"data":{"quality":80,"dash":{"video":[` + dashVideoJSON + `],"audio":[` + dashAudioJSON + `]}}}`},
+		{"番剧result.video_info节点", `{"code":0,"result":{"video_info":{"quality":80,"dash":{"video":[` + dashVideoJSON + `],"audio":[` + dashAudioJSON + `]}}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks, err := extractTracks(t, tt.json)
+			if err != nil {
+				t.Fatalf("ExtractTracks() error = %v", err)
+			}
+			if len(tracks) != 2 {
+				t.Fatalf("轨道数 = %d, want 2", len(tracks))
+			}
+
+			video, audio := tracks[0], tracks[1]
+			if video.URL != "https://backup/video.m4s" || len(video.BackupURLs) != 1 || video.BackupURLs[0] != "https://upos/video.m4s" {
+				t.Errorf("视频地址 = %v %v, 应优先使用backup_url", video.URL, video.BackupURLs)
+			}
+			if video.Width != 1920 || video.Height != 1080 || video.FPS != 30 || video.Codec != "AVC" || video.Codecs != "avc1.640032" || video.Bandwidth != 2000 {
+				t.Errorf("视频轨道 = %+v", video)
+			}
+			if audio.FrameType != "audio" || audio.Codec != "M4A" || audio.URL != "https://upos/audio.m4s" {
+				t.Errorf("音频轨道 = %+v", audio)
+			}
+		})
+	}
+}
+
+func TestParsePlayDataFlv(t *testing.T) {
+	tracks, err := extractTracks(t, `{"code":0,"data":{"quality":64,"format":"flv720","video_codecid":7,"durl":[`+
+		`{"order":2,"length":5000,"size":20,"url":"https://upos/2.flv"},`+
+		`{"order":1,"length":5000,"size":10,"url":"https://upos/1.flv","backup_url":["https://backup/1.flv"]}]}}`)
+	if err != nil {
+		t.Fatalf("ExtractTracks() error = %v", err)
+	}
+	if len(tracks) != 1 {
+		t.Fatalf("轨道数 = %d, want 1", len(tracks))
+	}
+
+	track := tracks[0]
+	if track.Quality != 64 || track.Size != 30 || len(track.Segments) != 2 {
+		t.Fatalf("FLV轨道 = %+v", track)
+	}
+	if track.Segments[0].URL != "https://upos/1.flv" || len(track.Segments[0].BackupURLs) != 1 {
+		t.Errorf("分段未按order排序: %+v", track.Segments)
+	}
+}
+
+func TestParsePlayDataInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{
+			name:    "视频缺少宽高",
+			json:    `{"code":0,"data":{"dash":{"video":[{"id":80,"base_url":"u","bandwidth":1,"codecs":"avc1"}]}}}`,
+			wantErr: "width/height",
+		},
+		{
+			name:    "音频缺少codecs",
+			json:    `{"code":0,"data":{"dash":{"audio":[{"id":30280,"base_url":"u","bandwidth":1}]}}}`,
+			wantErr: "codecs",
+		},
+		{
+			name:    "字段类型错误",
+			json:    `{"code":0,"data":{"dash":{"video":[{"id":"80"}]}}}`,
+			wantErr: "解析播放数据失败",
+		},
+		{
+			name:    "分段缺少地址",
+			json:    `{"code":0,"data":{"quality":64,"durl":[{"order":1,"size":10}]}}`,
+			wantErr: "缺少下载地址",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := extractTracks(t, tt.json)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ExtractTracks() error = %v, want 包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
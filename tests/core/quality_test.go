@@ -0,0 +1,54 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+func TestParseQuality(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		wantID int
+		wantOK bool
+	}{
+		{name: "画质代码", input: "80", wantID: 80, wantOK: true},
+		{name: "中文名称", input: "1080P 高清", wantID: 80, wantOK: true},
+		{name: "中文名称无空格", input: "1080P高码率", wantID: 112, wantOK: true},
+		{name: "英文名称", input: "dolby vision", wantID: 126, wantOK: true},
+		{name: "720P", input: "720P 高清", wantID: 64, wantOK: true},
+		{name: "未知代码", input: "999", wantOK: false},
+		{name: "未知名称", input: "超级清晰", wantOK: false},
+		{name: "空字符串", input: " ", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := core.ParseQuality(tt.input)
+			if ok != tt.wantOK {
+				t.Errorf("ParseQuality() ok = %v, want %v", ok, tt.wantOK)
+				return
+			}
+			if ok && got.ID != tt.wantID {
+				t.Errorf("ParseQuality() ID = %v, want %v", got.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestParseQualityPriority(t *testing.T) {
+	ids, unknown := core.ParseQualityPriority([]string{"8K 超高清", "", " 116 ", "未知"})
+	want := []int{127, 116}
+	if len(ids) != len(want) {
+		t.Fatalf("ParseQualityPriority() ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("ParseQualityPriority() ids[%d] = %v, want %v", i, ids[i], want[i])
+		}
+	}
+	if len(unknown) != 1 || unknown[0] != "未知" {
+		t.Errorf("ParseQualityPriority() unknown = %v, want [未知]", unknown)
+	}
+}
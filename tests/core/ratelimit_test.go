@@ -0,0 +1,37 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+func TestParseDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "空字符串", input: "", want: 0},
+		{name: "整数秒", input: "3", want: 3 * time.Second},
+		{name: "小数秒", input: "1.5", want: 1500 * time.Millisecond},
+		{name: "毫秒", input: "500ms", want: 500 * time.Millisecond},
+		{name: "带单位", input: "2s", want: 2 * time.Second},
+		{name: "负数", input: "-1", wantErr: true},
+		{name: "无效格式", input: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := core.ParseDelay(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDelay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
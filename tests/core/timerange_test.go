@@ -0,0 +1,39 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantStart time.Duration
+		wantEnd   time.Duration
+		wantErr   bool
+	}{
+		{name: "时分秒", input: "00:10:00-00:15:30", wantStart: 10 * time.Minute, wantEnd: 15*time.Minute + 30*time.Second},
+		{name: "分秒", input: "10:00-15:30", wantStart: 10 * time.Minute, wantEnd: 15*time.Minute + 30*time.Second},
+		{name: "秒", input: "600-930.5", wantStart: 10 * time.Minute, wantEnd: 930500 * time.Millisecond},
+		{name: "超过一小时", input: "1:59:59-2:00:01", wantStart: 2*time.Hour - time.Second, wantEnd: 2*time.Hour + time.Second},
+		{name: "结束早于开始", input: "00:15:00-00:10:00", wantErr: true},
+		{name: "缺少结束时间", input: "00:10:00", wantErr: true},
+		{name: "分钟超过59", input: "00:60:00-01:00:00", wantErr: true},
+		{name: "无效格式", input: "abc-def", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := core.ParseTimeRange(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("ParseTimeRange() = %v, %v, want %v, %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package core_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+)
+
+// wbiServer 模拟nav和一个需要WBI签名的接口，前rejects次签名请求返回-352
+func wbiServer(t *testing.T, rejects int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var navRequests, signed atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/x/web-interface/nav":
+			navRequests.Add(1)
+			fmt.Fprint(w, `{"code":0,"data":{"wbi_img":{`+
+				`"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",`+
+				`"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`)
+		case "/x/web-interface/view":
+			if r.URL.Query().Get("w_rid") == "" {
+				t.Errorf("请求没有WBI签名: %s", r.URL)
+			}
+			if signed.Add(1) <= rejects {
+				fmt.Fprint(w, `{"code":-352,"message":"风控校验失败"}`)
+				return
+			}
+			fmt.Fprint(w, `{"code":0,"data":{}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &navRequests
+}
+
+func TestGetWBISourceRefreshesKeyOnReject(t *testing.T) {
+	server, navRequests := wbiServer(t, 1)
+	client := core.NewHTTPClientWithOptions(core.HTTPOptions{
+		BaseURLs: map[string]string{"api.bilibili.com": server.URL},
+	})
+
+	resp, err := client.GetWBISource(server.URL+"/x/web-interface/view?", map[string]string{"bvid": "BV1xx411c7mD"}, "")
+	if err != nil {
+		t.Fatalf("GetWBISource() error = %v", err)
+	}
+	if resp != `{"code":0,"data":{}}` {
+		t.Errorf("GetWBISource() = %s", resp)
+	}
+	// 第一次签名被拒绝后清除缓存，重新获取密钥
+	if got := navRequests.Load(); got != 2 {
+		t.Errorf("nav请求%d次, want 2", got)
+	}
+}
+
+func TestWBIKeyCached(t *testing.T) {
+	server, navRequests := wbiServer(t, 0)
+	client := core.NewHTTPClientWithOptions(core.HTTPOptions{
+		BaseURLs: map[string]string{"api.bilibili.com": server.URL},
+	})
+
+	for i := 0; i < 3; i++ {
+		key, err := client.WBIKey()
+		if err != nil {
+			t.Fatalf("WBIKey() error = %v", err)
+		}
+		if key != "ea1db124af3c7062474693fa704f4ff8" {
+			t.Errorf("WBIKey() = %s", key)
+		}
+	}
+	if _, err := client.GetWBISource(server.URL+"/x/web-interface/view?", map[string]string{"aid": "1"}, ""); err != nil {
+		t.Fatalf("GetWBISource() error = %v", err)
+	}
+	if got := navRequests.Load(); got != 1 {
+		t.Errorf("nav请求%d次, want 1", got)
+	}
+}
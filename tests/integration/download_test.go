@@ -0,0 +1,465 @@
+package integration_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tekintian/go-bbdown/core"
+	"github.com/tekintian/go-bbdown/tests/fakebili"
+)
+
+// stubFFmpeg 模拟FFmpeg：拼接分段时按列表合并文件，混流时依次拼接所有输入，
+// 每次调用的参数追加到 <脚本路径>.log
+const stubFFmpeg = `#!/bin/sh
+echo "$*" >> "$0.log"
+inputs=""
+concat=0
+prev=""
+for arg in "$@"; do
+	case "$prev" in
+	-i) inputs="$inputs
+$arg" ;;
+	-f) [ "$arg" = concat ] && concat=1 ;;
+	esac
+	[ "$arg" != "-y" ] && out="$arg"
+	prev="$arg"
+done
+
+: > "$out"
+if [ "$concat" = 1 ]; then
+	list=$(printf '%s\n' "$inputs" | sed -n 2p)
+	sed -n "s/^file '\(.*\)'\$/\1/p" "$list" | while IFS= read -r f; do cat "$f" >> "$out"; done
+else
+	printf '%s\n' "$inputs" | sed 1d | while IFS= read -r f; do cat "$f" >> "$out"; done
+fi
+`
+
+// clipSize 多线程下载的分段大小，与 core 中的分段大小一致
+const clipSize = 20 * 1024 * 1024
+
+// testEnv 单个测试使用的模拟服务器、配置和FFmpeg调用记录
+type testEnv struct {
+	server  *fakebili.Server
+	config  *core.Config
+	workDir string
+	ffmpeg  string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("模拟FFmpeg依赖sh")
+	}
+
+	server := fakebili.New()
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte(stubFFmpeg), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := core.DefaultConfig()
+	config.WorkDir = filepath.Join(dir, "output")
+	config.FFmpegPath = ffmpeg
+	config.BaseURLs = server.BaseURLs()
+	config.APIRateLimit = 0
+
+	return &testEnv{server: server, config: config, workDir: config.WorkDir, ffmpeg: ffmpeg}
+}
+
+// ffmpegLog 返回模拟FFmpeg的调用记录
+func (e *testEnv) ffmpegLog(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(e.ffmpeg + ".log")
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// assertFile 检查输出文件内容
+func (e *testEnv) assertFile(t *testing.T, name string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(filepath.Join(e.workDir, name))
+	if err != nil {
+		t.Fatalf("读取输出文件失败: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s 内容不一致: 长度 %d, want %d", name, len(got), len(want))
+	}
+}
+
+// randomBytes 生成确定的伪随机内容
+func randomBytes(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// dashPage 生成DASH分P
+func dashPage(cid int64, part string, videoSize int) fakebili.Page {
+	return fakebili.Page{
+		Cid:   cid,
+		Part:  part,
+		Video: randomBytes(cid, videoSize),
+		Audio: randomBytes(cid+1, 64*1024),
+	}
+}
+
+// muxed 模拟FFmpeg混流后的内容
+func muxed(page fakebili.Page) []byte {
+	return append(append([]byte{}, page.Video...), page.Audio...)
+}
+
+func TestDownloadDASHMultiThread(t *testing.T) {
+	env := newTestEnv(t)
+	// 超过一个分段大小，多线程下载分为两段
+	page := dashPage(1001, "multi-thread", clipSize+512*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 1, Bvid: "BV1xx411c7mA", Title: "DASH", Pages: []fakebili.Page{page}})
+
+	if err := core.Download("BV1xx411c7mA", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	env.assertFile(t, "multi-thread.mp4", muxed(page))
+	log := env.ffmpegLog(t)
+	if !strings.Contains(log, "-map 0:v -map 1:a -c:v copy -c:a copy") {
+		t.Errorf("FFmpeg混流参数不正确: %s", log)
+	}
+	if _, err := os.Stat(filepath.Join(env.workDir, ".bbdown_tmp")); !os.IsNotExist(err) {
+		t.Errorf("下载成功后应删除临时目录")
+	}
+}
+
+func TestDownloadResumeAfterFailure(t *testing.T) {
+	env := newTestEnv(t)
+	page := dashPage(2001, "resume", clipSize+512*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 2, Bvid: "BV1xx411c7mB", Title: "Resume", Pages: []fakebili.Page{page}})
+
+	// 第二个分段第一次请求失败
+	videoPath := fakebili.VideoPath(page.Cid)
+	env.server.FailRanges(videoPath, 1)
+
+	if err := core.Download("BV1xx411c7mB", env.config); err == nil {
+		t.Fatal("第一次下载应失败")
+	}
+	clips, _ := filepath.Glob(filepath.Join(env.workDir, ".bbdown_tmp", "*", "video_*.mp4.00000.tmp"))
+	if len(clips) != 1 {
+		t.Fatalf("失败后应保留已完成的分段, 找到 %v", clips)
+	}
+
+	if err := core.Download("BV1xx411c7mB", env.config); err != nil {
+		t.Fatalf("恢复下载 error = %v", err)
+	}
+	env.assertFile(t, "resume.mp4", muxed(page))
+
+	// 第一次: HEAD + 两个分段；第二次: HEAD + 失败的分段，已完成的分段不重复下载
+	if got := env.server.Requests(videoPath); got != 5 {
+		t.Errorf("视频流请求数 = %d, want 5", got)
+	}
+}
+
+func TestDownloadFLVSegments(t *testing.T) {
+	env := newTestEnv(t)
+	segments := [][]byte{randomBytes(1, 100*1024), randomBytes(2, 80*1024), randomBytes(3, 50*1024)}
+	env.server.AddVideo(fakebili.Video{
+		Aid:   3,
+		Bvid:  "BV1xx411c7mC",
+		Title: "FLV",
+		Pages: []fakebili.Page{{Cid: 3001, Part: "segments", Segments: segments}},
+	})
+
+	if err := core.Download("BV1xx411c7mC", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	env.assertFile(t, "segments.mp4", bytes.Join(segments, nil))
+	if log := env.ffmpegLog(t); !strings.Contains(log, "-f concat") {
+		t.Errorf("应使用FFmpeg拼接分段: %s", log)
+	}
+}
+
+func TestDownloadFLVSegmentsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*core.Config)
+		want  string
+	}{
+		{name: "仅音频", setup: func(c *core.Config) { c.AudioOnly = true }, want: "不支持仅音频"},
+		{name: "跳过混流仍需FFmpeg", setup: func(c *core.Config) {
+			c.SkipMux = true
+			c.FFmpegPath = filepath.Join(t.TempDir(), "missing-ffmpeg")
+		}, want: "需要FFmpeg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			segments := [][]byte{randomBytes(4, 1024), randomBytes(5, 1024)}
+			env.server.AddVideo(fakebili.Video{
+				Aid:   4,
+				Bvid:  "BV1xx411c7mR",
+				Title: "FLV",
+				Pages: []fakebili.Page{{Cid: 3101, Part: "segments", Segments: segments}},
+			})
+			tt.setup(env.config)
+
+			err := core.Download("BV1xx411c7mR", env.config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Download() error = %v, want 包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadBangumi(t *testing.T) {
+	env := newTestEnv(t)
+	ep1 := dashPage(4001, "", 32*1024)
+	ep2 := dashPage(4002, "", 32*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 41, Bvid: "BV1xx411c7mD", Title: "EP1", Pages: []fakebili.Page{ep1}})
+	env.server.AddVideo(fakebili.Video{Aid: 42, Bvid: "BV1xx411c7mE", Title: "EP2", Pages: []fakebili.Page{ep2}})
+	env.server.AddSeason(fakebili.Season{
+		ID:    400,
+		Title: "测试番剧",
+		Episodes: []fakebili.Episode{
+			{ID: 40001, Bvid: "BV1xx411c7mD", Title: "1", LongTitle: "开始"},
+			{ID: 40002, Bvid: "BV1xx411c7mE", Title: "2", LongTitle: "结束"},
+		},
+	})
+
+	if err := core.Download("https://www.bilibili.com/bangumi/play/ep40001", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	env.assertFile(t, "1 开始_P1.mp4", muxed(ep1))
+	env.assertFile(t, "2 结束_P2.mp4", muxed(ep2))
+}
+
+func TestDownloadLists(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		add  func(*fakebili.Server, fakebili.List)
+		id   string
+	}{
+		{
+			name: "合集",
+			url:  "https://space.bilibili.com/100/lists/500?type=season",
+			add:  (*fakebili.Server).AddCollection,
+			id:   "500",
+		},
+		{
+			name: "收藏夹",
+			url:  "https://space.bilibili.com/100/lists/600?type=season",
+			add:  (*fakebili.Server).AddFavorite,
+			id:   "600",
+		},
+		{
+			name: "媒体列表",
+			url:  "https://www.bilibili.com/medialist/detail/ml700",
+			add:  (*fakebili.Server).AddMediaList,
+			id:   "700",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			first := dashPage(5001, "first", 16*1024)
+			second := dashPage(5002, "second", 16*1024)
+			env.server.AddVideo(fakebili.Video{Aid: 51, Bvid: "BV1xx411c7mF", Title: "first", Pages: []fakebili.Page{first}})
+			env.server.AddVideo(fakebili.Video{Aid: 52, Bvid: "BV1xx411c7mG", Title: "second", Pages: []fakebili.Page{second}})
+			tt.add(env.server, fakebili.List{ID: tt.id, Title: tt.name, Bvids: []string{"BV1xx411c7mF", "BV1xx411c7mG"}})
+
+			if err := core.Download(tt.url, env.config); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			env.assertFile(t, "first.mp4", muxed(first))
+			env.assertFile(t, "second.mp4", muxed(second))
+		})
+	}
+}
+
+func TestDownloadListReportsFailure(t *testing.T) {
+	env := newTestEnv(t)
+	first := dashPage(5101, "first", 16*1024)
+	second := dashPage(5102, "second", clipSize+512*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 53, Bvid: "BV1xx411c7mL", Title: "first", Pages: []fakebili.Page{first}})
+	env.server.AddVideo(fakebili.Video{Aid: 54, Bvid: "BV1xx411c7mM", Title: "second", Pages: []fakebili.Page{second}})
+	env.server.AddMediaList(fakebili.List{ID: "710", Title: "部分失败", Bvids: []string{"BV1xx411c7mL", "BV1xx411c7mM"}})
+	env.server.FailRanges(fakebili.VideoPath(second.Cid), 1)
+
+	// 单个视频失败不影响其他视频，但整个列表返回错误
+	err := core.Download("https://www.bilibili.com/medialist/detail/ml710", env.config)
+	if err == nil || !strings.Contains(err.Error(), "second") {
+		t.Fatalf("Download() error = %v, want 包含失败的视频", err)
+	}
+	env.assertFile(t, "first.mp4", muxed(first))
+}
+
+func TestRateLimitsPerConfig(t *testing.T) {
+	slow := newTestEnv(t)
+	fast := newTestEnv(t)
+	slowPage := dashPage(5201, "slow", 16*1024)
+	fastPage := dashPage(5202, "fast", 16*1024)
+	slow.server.AddVideo(fakebili.Video{Aid: 55, Bvid: "BV1xx411c7mN", Title: "slow", Pages: []fakebili.Page{slowPage}})
+	fast.server.AddVideo(fakebili.Video{Aid: 56, Bvid: "BV1xx411c7mP", Title: "fast", Pages: []fakebili.Page{fastPage}})
+
+	// 同时运行的两个配置各自限速，不会被后配置的覆盖
+	const interval = 200 * time.Millisecond
+	slow.config.APIRateLimit = float64(time.Second / interval)
+
+	var wg sync.WaitGroup
+	elapsed := make([]time.Duration, 2)
+	for i, env := range []*testEnv{slow, fast} {
+		wg.Add(1)
+		go func(i int, env *testEnv, bvid string) {
+			defer wg.Done()
+			start := time.Now()
+			if err := core.Download(bvid, env.config); err != nil {
+				t.Errorf("Download(%s) error = %v", bvid, err)
+			}
+			elapsed[i] = time.Since(start)
+		}(i, env, []string{"BV1xx411c7mN", "BV1xx411c7mP"}[i])
+	}
+	wg.Wait()
+
+	// nav、视频信息、播放地址三次接口请求
+	if elapsed[0] < 2*interval {
+		t.Errorf("限速的下载用时 %v, want >= %v", elapsed[0], 2*interval)
+	}
+	if elapsed[1] >= 2*interval {
+		t.Errorf("不限速的下载用时 %v, want < %v", elapsed[1], 2*interval)
+	}
+	slow.assertFile(t, "slow.mp4", muxed(slowPage))
+	fast.assertFile(t, "fast.mp4", muxed(fastPage))
+}
+
+func TestDelayPerPageAcrossURLs(t *testing.T) {
+	env := newTestEnv(t)
+	first := dashPage(5601, "first", 16*1024)
+	second := dashPage(5602, "second", 16*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 60, Bvid: "BV1xx411c7mY", Title: "first", Pages: []fakebili.Page{first}})
+	env.server.AddVideo(fakebili.Video{Aid: 61, Bvid: "BV1xx411c7mZ", Title: "second", Pages: []fakebili.Page{second}})
+	const delay = 300 * time.Millisecond
+	env.config.DelayPerPage = delay.String()
+
+	// 同一配置依次下载多个链接，链接之间也保持分P间隔
+	start := time.Now()
+	for _, bvid := range []string{"BV1xx411c7mY", "BV1xx411c7mZ"} {
+		if err := core.Download(bvid, env.config); err != nil {
+			t.Fatalf("Download(%s) error = %v", bvid, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("两个链接用时 %v, want >= %v", elapsed, delay)
+	}
+	env.assertFile(t, "first.mp4", muxed(first))
+	env.assertFile(t, "second.mp4", muxed(second))
+}
+
+func TestAudioPreferenceHiRes(t *testing.T) {
+	tests := []struct {
+		name       string
+		preference string
+		wantHiRes  bool
+	}{
+		{name: "默认不选择Hi-Res", preference: "", wantHiRes: false},
+		{name: "指定hires", preference: core.AudioPreferHiRes, wantHiRes: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			page := dashPage(5301, "hires", 16*1024)
+			page.HiResAudio = randomBytes(5302, 32*1024)
+			env.server.AddVideo(fakebili.Video{Aid: 57, Bvid: "BV1xx411c7mS", Title: "hires", Pages: []fakebili.Page{page}})
+			env.config.AudioPreference = tt.preference
+
+			if err := core.Download("BV1xx411c7mS", env.config); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			gotHiRes := env.server.Requests(fakebili.HiResPath(page.Cid)) > 0
+			if gotHiRes != tt.wantHiRes {
+				t.Errorf("下载了Hi-Res音频 = %v, want %v", gotHiRes, tt.wantHiRes)
+			}
+			if gotAAC := env.server.Requests(fakebili.AudioPath(page.Cid)) > 0; gotAAC == tt.wantHiRes {
+				t.Errorf("下载了AAC音频 = %v, want %v", gotAAC, !tt.wantHiRes)
+			}
+		})
+	}
+}
+
+func TestTrackLimitsNotMet(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  func(*core.Config)
+		reason string
+	}{
+		{name: "分辨率", limit: func(c *core.Config) { c.MaxResolution = 720 }, reason: "分辨率1080p超过上限720p"},
+		{name: "码率", limit: func(c *core.Config) { c.MaxBitrate = 100 }, reason: "超过上限100kbps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			page := dashPage(5401, "limit", 16*1024)
+			env.server.AddVideo(fakebili.Video{Aid: 58, Bvid: "BV1xx411c7mT", Title: "limit", Pages: []fakebili.Page{page}})
+			tt.limit(env.config)
+
+			// 没有轨道满足限制时报错，不忽略限制下载
+			err := core.Download("BV1xx411c7mT", env.config)
+			if err == nil || !strings.Contains(err.Error(), tt.reason) {
+				t.Fatalf("Download() error = %v, want 包含 %q", err, tt.reason)
+			}
+			if got := env.server.Requests(fakebili.VideoPath(page.Cid)); got != 0 {
+				t.Errorf("请求视频流%d次, want 0", got)
+			}
+		})
+	}
+}
+
+func TestSaveAudioFailureKeepsTempFiles(t *testing.T) {
+	env := newTestEnv(t)
+	page := dashPage(5501, "keep", 16*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 59, Bvid: "BV1xx411c7mU", Title: "keep", Pages: []fakebili.Page{page}})
+	archivePath := filepath.Join(t.TempDir(), "archive.txt")
+	env.config.DownloadArchive = archivePath
+	env.config.AudioOnly = true
+	env.config.AudioFormat = "mp3"
+	env.config.FFmpegPath = filepath.Join(t.TempDir(), "missing-ffmpeg")
+
+	// 转码失败时返回错误，保留临时文件且不写入下载记录
+	if err := core.Download("BV1xx411c7mU", env.config); err == nil {
+		t.Fatal("Download() error = nil, want 保存音频失败")
+	}
+	tempDir := filepath.Join(env.workDir, ".bbdown_tmp", fmt.Sprintf("59_%d", page.Cid))
+	if entries, err := os.ReadDir(tempDir); err != nil || len(entries) == 0 {
+		t.Fatalf("临时文件未保留: %v", err)
+	}
+	if data, _ := os.ReadFile(archivePath); strings.Contains(string(data), "BV1xx411c7mU") {
+		t.Fatalf("失败的分P写入了下载记录: %s", data)
+	}
+
+	// 修复FFmpeg后重新运行，完成后写入下载记录
+	env.config.FFmpegPath = env.ffmpeg
+	if err := core.Download("BV1xx411c7mU", env.config); err != nil {
+		t.Fatalf("重新运行 Download() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(env.workDir, "keep.mp3")); err != nil {
+		t.Errorf("音频未保存: %v", err)
+	}
+	if data, _ := os.ReadFile(archivePath); !strings.Contains(string(data), "BV1xx411c7mU") {
+		t.Errorf("下载记录 = %q, want 包含 BV1xx411c7mU", data)
+	}
+}
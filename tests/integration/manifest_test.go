@@ -0,0 +1,188 @@
+package integration_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tekintian/go-bbdown/core"
+	"github.com/tekintian/go-bbdown/tests/fakebili"
+)
+
+// indexedPage 生成视频和音频都带有sidx索引的分P，返回分P和视频、音频的分段内容
+// 初始化段分别为100和80字节，每个分段4秒
+func indexedPage(cid int64, part string) (fakebili.Page, [][]byte, [][]byte) {
+	videoSegments := [][]byte{randomBytes(cid, 3000), randomBytes(cid+1, 2000), randomBytes(cid+2, 1000)}
+	audioSegments := [][]byte{randomBytes(cid+3, 300), randomBytes(cid+4, 200), randomBytes(cid+5, 100)}
+	video, videoIndex := fakebili.DashMedia(randomBytes(cid+6, 100), videoSegments, 4*time.Second)
+	audio, audioIndex := fakebili.DashMedia(randomBytes(cid+7, 80), audioSegments, 4*time.Second)
+	return fakebili.Page{
+		Cid:        cid,
+		Part:       part,
+		Duration:   12 * time.Second,
+		Video:      video,
+		Audio:      audio,
+		VideoIndex: videoIndex,
+		AudioIndex: audioIndex,
+	}, videoSegments, audioSegments
+}
+
+func TestExportMPD(t *testing.T) {
+	env := newTestEnv(t)
+	page, _, _ := indexedPage(6001, "mpd")
+	env.server.AddVideo(fakebili.Video{Aid: 6, Bvid: "BV1xx411c7mH", Title: "MPD", Pages: []fakebili.Page{page}})
+	env.config.ExportMPD = true
+
+	if err := core.Download("BV1xx411c7mH", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.workDir, "mpd.mpd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mpd struct {
+		Type     string `xml:"type,attr"`
+		Duration string `xml:"mediaPresentationDuration,attr"`
+		Sets     []struct {
+			ContentType     string `xml:"contentType,attr"`
+			Representations []struct {
+				Codecs      string `xml:"codecs,attr"`
+				Bandwidth   int    `xml:"bandwidth,attr"`
+				BaseURL     string `xml:"BaseURL"`
+				SegmentBase struct {
+					IndexRange string `xml:"indexRange,attr"`
+					Init       struct {
+						Range string `xml:"range,attr"`
+					} `xml:"Initialization"`
+				} `xml:"SegmentBase"`
+			} `xml:"Representation"`
+		} `xml:"Period>AdaptationSet"`
+	}
+	if err := xml.Unmarshal(data, &mpd); err != nil {
+		t.Fatalf("MPD不是有效的XML: %v", err)
+	}
+
+	if mpd.Type != "static" || mpd.Duration != "PT12.000S" || len(mpd.Sets) != 2 {
+		t.Fatalf("MPD = %s", data)
+	}
+	video := mpd.Sets[0].Representations[0]
+	if mpd.Sets[0].ContentType != "video" || video.Codecs != "avc1.640032" || video.Bandwidth != 2000000 {
+		t.Errorf("视频Representation = %+v", video)
+	}
+	if video.BaseURL != env.server.URL+fakebili.VideoPath(page.Cid) ||
+		video.SegmentBase.IndexRange != page.VideoIndex.IndexRange ||
+		video.SegmentBase.Init.Range != page.VideoIndex.Initialization {
+		t.Errorf("视频Representation地址或SegmentBase不正确: %+v", video)
+	}
+	if audio := mpd.Sets[1].Representations[0]; audio.SegmentBase.IndexRange != page.AudioIndex.IndexRange {
+		t.Errorf("音频Representation = %+v", audio)
+	}
+
+	// 只生成清单，不下载
+	if got := env.server.Requests(fakebili.VideoPath(page.Cid)); got != 0 {
+		t.Errorf("导出MPD时请求了%d次视频流", got)
+	}
+}
+
+func TestExportHLS(t *testing.T) {
+	env := newTestEnv(t)
+	page, videoSegments, _ := indexedPage(7001, "hls")
+	env.server.AddVideo(fakebili.Video{Aid: 7, Bvid: "BV1xx411c7mJ", Title: "HLS", Pages: []fakebili.Page{page}})
+	env.config.ExportHLS = true
+
+	if err := core.Download("BV1xx411c7mJ", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	master, err := os.ReadFile(filepath.Join(env.workDir, "hls.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-m4a"`,
+		`CODECS="avc1.640032,mp4a.40.2",RESOLUTION=1920x1080`,
+		"hls.video_80_avc.m3u8",
+	} {
+		if !strings.Contains(string(master), want) {
+			t.Errorf("主播放列表缺少 %q:\n%s", want, master)
+		}
+	}
+
+	media, err := os.ReadFile(filepath.Join(env.workDir, "hls.video_80_avc.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(media), `#EXT-X-MAP:URI="`+env.server.URL+fakebili.VideoPath(page.Cid)+`",BYTERANGE="100@0"`) ||
+		!strings.Contains(string(media), "#EXT-X-TARGETDURATION:4") {
+		t.Errorf("媒体播放列表 =\n%s", media)
+	}
+
+	// 按播放列表中的字节范围请求，应得到对应的分段
+	ranges := regexp.MustCompile(`#EXT-X-BYTERANGE:(\d+)@(\d+)`).FindAllStringSubmatch(string(media), -1)
+	if len(ranges) != len(videoSegments) {
+		t.Fatalf("分段数 = %d, want %d", len(ranges), len(videoSegments))
+	}
+	for i, match := range ranges {
+		length, _ := strconv.Atoi(match[1])
+		offset, _ := strconv.Atoi(match[2])
+		got := fetchRange(t, env.server.URL+fakebili.VideoPath(page.Cid), offset, offset+length-1)
+		if string(got) != string(videoSegments[i]) {
+			t.Errorf("第%d个分段内容不一致", i+1)
+		}
+	}
+}
+
+// fetchRange 请求媒体文件的指定范围
+func fetchRange(t *testing.T, url string, start, end int) []byte {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestExportMPDFromSeason(t *testing.T) {
+	env := newTestEnv(t)
+	first, _, _ := indexedPage(6201, "first")
+	second, _, _ := indexedPage(6211, "second")
+	env.server.AddVideo(fakebili.Video{Aid: 62, Bvid: "BV1xx411c7mV", Title: "first", Pages: []fakebili.Page{first}})
+	env.server.AddVideo(fakebili.Video{Aid: 63, Bvid: "BV1xx411c7mW", Title: "second", Pages: []fakebili.Page{second}})
+	env.server.AddCollection(fakebili.List{ID: "520", Title: "清单合集", Bvids: []string{"BV1xx411c7mV", "BV1xx411c7mW"}})
+	env.config.ExportMPD = true
+
+	// 合集中的每个视频只导出清单，不下载音视频
+	if err := core.Download("https://space.bilibili.com/100/lists/520?type=season", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	entries, err := os.ReadDir(env.workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got, want := strings.Join(names, ","), "first.mpd,second.mpd"; got != want {
+		t.Errorf("输出文件 = %s, want %s", got, want)
+	}
+	if log := env.ffmpegLog(t); log != "" {
+		t.Errorf("导出清单时调用了FFmpeg: %s", log)
+	}
+}
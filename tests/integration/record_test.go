@@ -0,0 +1,57 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+	"github.com/tekintian/go-bbdown/tests/fakebili"
+)
+
+func TestRecordRedactsLoginCookie(t *testing.T) {
+	env := newTestEnv(t)
+	page := dashPage(9001, "record", 16*1024)
+	env.server.AddVideo(fakebili.Video{Aid: 90, Bvid: "BV1xx411c7mX", Title: "record", Pages: []fakebili.Page{page}})
+	const secret = "SESSDATA=logged-in-secret"
+	dir := t.TempDir()
+	env.config.Cookie = secret
+	env.config.RecordDir = dir
+
+	if err := core.Download("BV1xx411c7mX", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) == 0 {
+		t.Fatal("没有录制文件")
+	}
+	// 登录Cookie随请求发送，但录制文件中只保留隐藏后的值
+	sent := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "logged-in-secret") {
+			t.Errorf("%s 包含登录Cookie", filepath.Base(file))
+		}
+		var fixture struct {
+			RequestHeader map[string][]string `json:"requestHeader"`
+		}
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			t.Fatalf("%s 不是有效的JSON: %v", filepath.Base(file), err)
+		}
+		if cookies := fixture.RequestHeader["Cookie"]; len(cookies) > 0 {
+			sent++
+			if cookies[0] != "REDACTED" {
+				t.Errorf("%s Cookie = %q, want REDACTED", filepath.Base(file), cookies[0])
+			}
+		}
+	}
+	if sent == 0 {
+		t.Error("录制的请求都没有带上Cookie")
+	}
+}
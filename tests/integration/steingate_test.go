@@ -0,0 +1,101 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+	"github.com/tekintian/go-bbdown/tests/fakebili"
+)
+
+// steinVideo 互动视频：开始 -> 向左/向右，向左可回到开始，两条分支汇合到同一结局
+func steinVideo() (fakebili.Video, []fakebili.Page) {
+	pages := []fakebili.Page{
+		dashPage(8001, "开始", 2048),
+		dashPage(8002, "左边", 2048),
+		dashPage(8003, "右边", 2048),
+		dashPage(8004, "结局", 2048),
+	}
+	return fakebili.Video{
+		Aid:   8,
+		Bvid:  "BV1xx411c7mK",
+		Title: "互动",
+		Pages: pages,
+		Stein: []fakebili.SteinNode{
+			{EdgeID: 1, Cid: 8001, Title: "开始", Choices: []fakebili.SteinChoice{{Option: "向左", EdgeID: 2}, {Option: "向右", EdgeID: 3}}},
+			{EdgeID: 2, Cid: 8002, Title: "左边", Choices: []fakebili.SteinChoice{{Option: "回到开始", EdgeID: 1}, {Option: "继续", EdgeID: 4}}},
+			{EdgeID: 3, Cid: 8003, Title: "右边", Choices: []fakebili.SteinChoice{{Option: "继续前进", EdgeID: 4}}},
+			{EdgeID: 4, Cid: 8004, Title: "结局"},
+		},
+	}, pages
+}
+
+func TestDownloadSteinGate(t *testing.T) {
+	env := newTestEnv(t)
+	video, pages := steinVideo()
+	env.server.AddVideo(video)
+	env.config.SteinGraph = "json"
+
+	if err := core.Download("BV1xx411c7mK", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	// 每个节点按广度优先顺序作为一个分P，标题为到达该节点的选项
+	for i, name := range []string{"开始_P1.mp4", "向左_P2.mp4", "向右_P3.mp4", "继续_P4.mp4"} {
+		env.assertFile(t, name, muxed(pages[i]))
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.workDir, "互动.stein.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph core.SteinGraph
+	if err := json.Unmarshal(data, &graph); err != nil {
+		t.Fatalf("剧情图不是有效的JSON: %v", err)
+	}
+	if graph.RootEdgeID != 1 || len(graph.Nodes) != 4 {
+		t.Fatalf("剧情图 = %s", data)
+	}
+	if choices := graph.Nodes[1].Choices; len(choices) != 2 || choices[0].EdgeID != 1 || choices[1].Cid != 8004 {
+		t.Errorf("节点2的选项 = %+v", choices)
+	}
+	if last := graph.Nodes[3]; !last.IsLeaf || last.Cid != 8004 {
+		t.Errorf("结局节点 = %+v", last)
+	}
+
+	// 回到开始的循环和汇合的分支不会重复请求节点
+	if got := env.server.Requests("/x/stein/edgeinfo_v2"); got != 4 {
+		t.Errorf("请求节点信息%d次, want 4", got)
+	}
+}
+
+func TestExportSteinGraphDOT(t *testing.T) {
+	env := newTestEnv(t)
+	video, _ := steinVideo()
+	env.server.AddVideo(video)
+	env.config.SteinGraph = "dot"
+	env.config.OnlyShowInfo = true
+	env.config.HideStreams = true
+
+	if err := core.Download("BV1xx411c7mK", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.workDir, "互动.stein.dot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`digraph "互动" {`,
+		`4 [label="结局", peripheries=2];`,
+		`2 -> 1 [label="回到开始"];`,
+		`3 -> 4 [label="继续前进"];`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("DOT缺少 %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package integration_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/core"
+	"github.com/tekintian/go-bbdown/tests/fakebili"
+)
+
+func TestDownloadTimeRange(t *testing.T) {
+	env := newTestEnv(t)
+	page, videoSegments, audioSegments := indexedPage(8001, "range")
+	env.server.AddVideo(fakebili.Video{Aid: 8, Bvid: "BV1xx411c7mK", Title: "Range", Pages: []fakebili.Page{page}})
+	// 分段时长4秒，00:05-00:07只在第二个分段内
+	env.config.TimeRange = "00:00:05-00:00:07"
+
+	if err := core.Download("BV1xx411c7mK", env.config); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	// 模拟FFmpeg按顺序拼接输入：视频初始化段和第二个分段，然后是音频
+	var want []byte
+	want = append(want, page.Video[:100]...)
+	want = append(want, videoSegments[1]...)
+	want = append(want, page.Audio[:80]...)
+	want = append(want, audioSegments[1]...)
+	env.assertFile(t, "range [00.00.05-00.00.07].mp4", want)
+
+	log := env.ffmpegLog(t)
+	if !strings.Contains(log, "-ss 1.000 -i") || !strings.Contains(log, "-t 2.000") {
+		t.Errorf("FFmpeg剪切参数不正确: %s", log)
+	}
+}
+
+func TestDownloadTimeRangeCodecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		quality int
+		codecid int
+		file    string
+		args    string
+	}{
+		{name: "HEVC重新编码为HEVC", quality: 80, codecid: 12, file: "range [00.00.05-00.00.07].mp4", args: "-c:v libx265"},
+		{name: "AV1重新编码为AV1", quality: 80, codecid: 13, file: "range [00.00.05-00.00.07].mp4", args: "-c:v libsvtav1"},
+		{name: "HDR直接复制", quality: 125, codecid: 12, file: "range [HDR] [00.00.05-00.00.07].mp4", args: "-c:v copy -strict unofficial -tag:v hvc1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			page, _, _ := indexedPage(8101, "range")
+			page.Quality = tt.quality
+			page.Codecid = tt.codecid
+			// 优先使用的backup_url不可用，回退到base_url
+			page.BrokenBackupURL = true
+			env.server.AddVideo(fakebili.Video{Aid: 9, Bvid: "BV1xx411c7mQ", Title: "Range", Pages: []fakebili.Page{page}})
+			env.config.TimeRange = "00:00:05-00:00:07"
+
+			if err := core.Download("BV1xx411c7mQ", env.config); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(env.workDir, tt.file)); err != nil {
+				t.Errorf("缺少输出文件: %v", err)
+			}
+			if log := env.ffmpegLog(t); !strings.Contains(log, tt.args) || strings.Contains(log, "libx264") {
+				t.Errorf("FFmpeg参数应包含 %q: %s", tt.args, log)
+			}
+			if env.server.Requests("/media/missing/video.m4s") == 0 {
+				t.Errorf("应先尝试backup_url")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package util_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tekintian/go-bbdown/util"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{name: "空字符串", input: "", want: 0},
+		{name: "纯数字", input: "1024", want: 1024},
+		{name: "KB", input: "2K", want: 2048},
+		{name: "MB带B后缀", input: "500MB", want: 500 * 1024 * 1024},
+		{name: "GiB小写", input: "1.5gib", want: 1536 * 1024 * 1024},
+		{name: "无效输入", input: "abc", wantErr: true},
+		{name: "负数", input: "-1M", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := util.ParseByteSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 错误信息引用用户的原始输入
+	if _, err := util.ParseByteSize(" 12xb "); err == nil || !strings.Contains(err.Error(), `" 12xb "`) {
+		t.Errorf("ParseByteSize() error = %v, want 包含原始输入", err)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mp4")
+	dst := filepath.Join(dir, "out", "dst.mp4")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.MoveFile(src, dst); err != nil {
+		t.Fatalf("MoveFile() error = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("MoveFile() 后源文件仍然存在")
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "data" {
+		t.Errorf("MoveFile() 目标文件内容 = %q, err = %v", data, err)
+	}
+
+	if err := util.MoveFile(src, dst); err == nil {
+		t.Errorf("MoveFile() 源文件不存在时应返回错误")
+	}
+}
@@ -395,3 +395,73 @@ func TestGetQueryString(t *testing.T) {
 		})
 	}
 }
+
+func TestReadURLList(t *testing.T) {
+	input := `# 每晚下载的收藏夹
+BV1xx411c7mD
+
+  https://www.bilibili.com/video/BV1xx411c7mD#reply  
+ep123456 # 番剧
+	# 已下载
+ss123456
+`
+	got, err := util.ReadURLList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadURLList() error = %v", err)
+	}
+
+	want := []string{
+		"BV1xx411c7mD",
+		"https://www.bilibili.com/video/BV1xx411c7mD#reply",
+		"ep123456",
+		"ss123456",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadURLList() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ReadURLList()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGetMixinKey(t *testing.T) {
+	// 官方文档示例：img_key + sub_key
+	orig := "7cd084941338484aae1ad9425b84077c" + "4932caff0ff746eab6f01bf08b70ac45"
+	want := "ea1db124af3c7062474693fa704f4ff8"
+	if got := util.GetMixinKey(orig); got != want {
+		t.Errorf("GetMixinKey() = %v, want %v", got, want)
+	}
+}
+
+func TestWBISign(t *testing.T) {
+	mixinKey := "ea1db124af3c7062474693fa704f4ff8"
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   string
+	}{
+		{
+			name:   "按key排序",
+			params: map[string]string{"foo": "114", "bar": "514", "zab": "1919810"},
+			want:   "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+		},
+		{
+			name:   "过滤特殊字符并编码空格",
+			params: map[string]string{"keyword": "a b!(c)*'"},
+			want:   "keyword=a%20bc&wts=1702204169&w_rid=71db71f808043de3cbf856b5075f8014",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := util.WBISign(tt.params, mixinKey, 1702204169); got != tt.want {
+				t.Errorf("WBISign() = %v, want %v", got, tt.want)
+			}
+			if _, ok := tt.params["wts"]; ok {
+				t.Errorf("WBISign() 不应修改传入的参数")
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -72,6 +73,46 @@ func CopyFile(src, dst string) error {
 	return err
 }
 
+// MoveFile 移动文件，重命名失败（如跨文件系统）时复制后删除源文件
+func MoveFile(src, dst string) error {
+	renameErr := os.Rename(src, dst)
+	if renameErr == nil {
+		return nil
+	}
+	if _, err := os.Stat(src); err != nil {
+		return renameErr
+	}
+
+	if err := copyFileSync(src, dst); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("移动文件失败: %w", err)
+	}
+	return os.Remove(src)
+}
+
+// copyFileSync 复制文件并确保数据写入磁盘
+func copyFileSync(src, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destFile, sourceFile); err != nil {
+		destFile.Close()
+		return err
+	}
+	if err := destFile.Sync(); err != nil {
+		destFile.Close()
+		return err
+	}
+	return destFile.Close()
+}
+
 // ReadFile 读取文件
 func ReadFile(path string) ([]byte, error) {
 	return os.ReadFile(path)
@@ -169,6 +210,34 @@ func FormatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// ParseByteSize 解析可读的字节大小，如 "500M"、"1.5GB"、"1024"
+// 空字符串返回0，单位按1024进制计算
+func ParseByteSize(input string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(input))
+	if s == "" {
+		return 0, nil
+	}
+
+	s = strings.TrimSuffix(s, "IB")
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		if idx := strings.IndexByte("KMGT", s[n-1]); idx >= 0 {
+			for i := 0; i <= idx; i++ {
+				multiplier *= 1024
+			}
+			s = s[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("无效的大小: %q", input)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
 // FormatDuration 格式化时长
 func FormatDuration(seconds int) string {
 	if seconds < 0 {
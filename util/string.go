@@ -1,10 +1,14 @@
 package util
 
 import (
+	"bufio"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -276,10 +280,35 @@ func Sign(params, appKey string, appSecret string) string {
 	return MD5Hash(text)
 }
 
-// WBISign WBI签名
-func WBISign(api string, wbiKey string) string {
-	text := api + wbiKey
-	return MD5Hash(text)
+// wbiValueFilter WBI签名前需要从参数值中去掉的字符
+var wbiValueFilter = strings.NewReplacer("!", "", "'", "", "(", "", ")", "", "*", "")
+
+// WBISign WBI签名，返回附带wts和w_rid的查询字符串
+// 参数按key排序，值中去掉 !'()* 后按encodeURIComponent规则编码，再拼接混合密钥计算MD5
+func WBISign(params map[string]string, mixinKey string, wts int64) string {
+	signed := make(map[string]string, len(params)+1)
+	for key, value := range params {
+		signed[key] = wbiValueFilter.Replace(value)
+	}
+	signed["wts"] = strconv.FormatInt(wts, 10)
+
+	keys := make([]string, 0, len(signed))
+	for key := range signed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = wbiEscape(key) + "=" + wbiEscape(signed[key])
+	}
+	query := strings.Join(pairs, "&")
+	return query + "&w_rid=" + MD5Hash(query+mixinKey)
+}
+
+// wbiEscape 按encodeURIComponent规则编码，空格编码为%20
+func wbiEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
 }
 
 // GetMixinKey 获取WBI混合密钥
@@ -313,13 +342,6 @@ func RSubString(sub string) string {
 	return sub[:lastDot]
 }
 
-// GetWBIKey 获取WBI密钥
-func GetWBIKey(client interface{}) (string, error) {
-	// 这里需要传入HTTP客户端来获取WBI密钥
-	// 暂时返回空字符串，稍后在core包中实现
-	return "", nil
-}
-
 // FormatTimestamp 格式化时间戳
 func FormatTimestamp(timestamp int64, format string) string {
 	if timestamp == 0 {
@@ -405,3 +427,23 @@ func NormalizeID(id string) string {
 	}
 	return id
 }
+
+// ReadURLList 读取URL列表，每行一个，忽略空行和以#开头的注释行，行尾的 # 注释同样忽略
+func ReadURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}